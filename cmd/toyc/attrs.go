@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/pkg/errors"
+)
+
+// stackProtectorAttr returns the function attribute of the given stack
+// protector mode, as specified by the -stack-protector flag. The boolean return
+// value indicates whether stack protection is enabled.
+func stackProtectorAttr(mode string) (ir.FuncAttribute, bool, error) {
+	switch mode {
+	case "":
+		// stack protection disabled.
+		return nil, false, nil
+	case "ssp":
+		// Protect functions with character arrays or large buffers.
+		return enum.FuncAttrSSP, true, nil
+	case "strong":
+		// Protect functions with any local arrays or address-taken locals.
+		return enum.FuncAttrSSPStrong, true, nil
+	case "all":
+		// Protect all functions.
+		return enum.FuncAttrSSPReq, true, nil
+	default:
+		return nil, false, errors.Errorf(`invalid stack protector mode %q; expected "ssp", "strong" or "all"`, mode)
+	}
+}
+
+// framePointerAttr is the function attribute which requests that the frame
+// pointer is retained in all functions.
+var framePointerAttr = ir.AttrPair{Key: "frame-pointer", Value: "all"}
+
+// targetAttrs returns the function attributes of the given target CPU and
+// features, as specified by the -march flag (e.g. "skylake" or
+// "skylake,+avx2,-sse4a"). The first comma-separated entry is the target CPU,
+// and the remaining entries are target features, each prefixed by '+' to
+// enable or '-' to disable the feature.
+func targetAttrs(march string) ([]ir.FuncAttribute, error) {
+	if len(march) == 0 {
+		// target CPU unspecified.
+		return nil, nil
+	}
+	parts := strings.Split(march, ",")
+	cpu, features := parts[0], parts[1:]
+	if len(cpu) == 0 {
+		return nil, errors.Errorf("invalid -march %q; missing target CPU", march)
+	}
+	attrs := []ir.FuncAttribute{ir.AttrPair{Key: "target-cpu", Value: cpu}}
+	if len(features) == 0 {
+		return attrs, nil
+	}
+	for _, feature := range features {
+		if !strings.HasPrefix(feature, "+") && !strings.HasPrefix(feature, "-") {
+			return nil, errors.Errorf("invalid target feature %q of -march %q; expected '+' or '-' prefix", feature, march)
+		}
+	}
+	attrs = append(attrs, ir.AttrPair{Key: "target-features", Value: strings.Join(features, ",")})
+	return attrs, nil
+}
+
+// addFuncAttrs adds the given function attributes to each function definition
+// of the LLVM IR module.
+func addFuncAttrs(m *ir.Module, attrs ...ir.FuncAttribute) {
+	if len(attrs) == 0 {
+		return
+	}
+	for _, f := range m.Funcs {
+		if len(f.Blocks) == 0 {
+			// Skip function declarations.
+			continue
+		}
+		f.FuncAttrs = append(f.FuncAttrs, attrs...)
+	}
+}
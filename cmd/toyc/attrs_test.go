@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestStackProtectorAttr(t *testing.T) {
+	m := ir.NewModule()
+	def := m.NewFunc("f", types.Void)
+	def.NewBlock("").NewRet(nil)
+	decl := m.NewFunc("g", types.Void)
+	attr, ok, err := stackProtectorAttr("strong")
+	if err != nil {
+		t.Fatalf("unable to get stack protector attribute; %+v", err)
+	}
+	if !ok {
+		t.Fatalf("stack protection disabled for mode %q", "strong")
+	}
+	addFuncAttrs(m, attr)
+	if len(def.FuncAttrs) != 1 || def.FuncAttrs[0] != enum.FuncAttrSSPStrong {
+		t.Errorf("function attributes mismatch of definition; expected [%v], got %v", enum.FuncAttrSSPStrong, def.FuncAttrs)
+	}
+	// Function declarations are left unchanged.
+	if len(decl.FuncAttrs) != 0 {
+		t.Errorf("function attributes mismatch of declaration; expected none, got %v", decl.FuncAttrs)
+	}
+	// Stack protection disabled by default.
+	if _, ok, err := stackProtectorAttr(""); ok || err != nil {
+		t.Errorf("stack protection mismatch of empty mode; expected disabled, got enabled=%v, err=%v", ok, err)
+	}
+	if _, _, err := stackProtectorAttr("weak"); err == nil {
+		t.Errorf("expected error for invalid stack protector mode %q", "weak")
+	}
+}
+
+func TestTargetAttrs(t *testing.T) {
+	attrs, err := targetAttrs("skylake,+avx2,-sse4a")
+	if err != nil {
+		t.Fatalf("unable to get target attributes; %+v", err)
+	}
+	want := []ir.FuncAttribute{
+		ir.AttrPair{Key: "target-cpu", Value: "skylake"},
+		ir.AttrPair{Key: "target-features", Value: "+avx2,-sse4a"},
+	}
+	if len(attrs) != len(want) {
+		t.Fatalf("number of target attributes mismatch; expected %d, got %d", len(want), len(attrs))
+	}
+	for i := range want {
+		if attrs[i] != want[i] {
+			t.Errorf("target attribute %d mismatch; expected %v, got %v", i, want[i], attrs[i])
+		}
+	}
+	m := ir.NewModule()
+	f := m.NewFunc("f", types.Void)
+	f.NewBlock("").NewRet(nil)
+	addFuncAttrs(m, attrs[:1]...)
+	if len(f.FuncAttrs) != 1 || f.FuncAttrs[0] != want[0] {
+		t.Errorf("function attributes mismatch; expected [%v], got %v", want[0], f.FuncAttrs)
+	}
+	// Target CPU unspecified.
+	if attrs, err := targetAttrs(""); len(attrs) != 0 || err != nil {
+		t.Errorf("target attributes mismatch of empty -march; expected none, got %v (err=%v)", attrs, err)
+	}
+	for _, march := range []string{",+avx2", "skylake,avx2"} {
+		if _, err := targetAttrs(march); err == nil {
+			t.Errorf("expected error for invalid -march %q", march)
+		}
+	}
+}
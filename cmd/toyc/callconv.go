@@ -0,0 +1,80 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/pkg/errors"
+)
+
+// callingConv returns the calling convention of the given name, as specified
+// by the -callconv flag.
+func callingConv(name string) (enum.CallingConv, error) {
+	switch name {
+	case "", "ccc":
+		// C calling convention; the default.
+		return enum.CallingConvNone, nil
+	case "fastcc":
+		return enum.CallingConvFast, nil
+	case "coldcc":
+		return enum.CallingConvCold, nil
+	case "preserve_mostcc":
+		return enum.CallingConvPreserveMost, nil
+	case "preserve_allcc":
+		return enum.CallingConvPreserveAll, nil
+	default:
+		return 0, errors.Errorf(`invalid calling convention %q; expected "ccc", "fastcc", "coldcc", "preserve_mostcc" or "preserve_allcc"`, name)
+	}
+}
+
+// setCallingConv sets the given calling convention on the generated functions
+// of the compiled LLVM IR modules and their call sites, as requested by the
+// -callconv flag.
+//
+// Generated functions are those defined by any of the compiled modules, thus
+// functions of imported packages declared in a module are included. Entry
+// points invoked by the runtime (main and package initializers) and external
+// functions (e.g. runtime helpers and C functions) retain the C calling
+// convention. Indirect calls use the given calling convention, as function
+// values refer to generated functions.
+func setCallingConv(modules []*module, cc enum.CallingConv) {
+	if cc == enum.CallingConvNone {
+		return
+	}
+	generated := make(map[string]bool)
+	for _, m := range modules {
+		for _, f := range m.Funcs {
+			if len(f.Blocks) > 0 && !isEntryPoint(f) {
+				generated[f.Name()] = true
+			}
+		}
+	}
+	for _, m := range modules {
+		for _, f := range m.Funcs {
+			if generated[f.Name()] {
+				f.CallingConv = cc
+			}
+			for _, block := range f.Blocks {
+				for _, inst := range block.Insts {
+					call, ok := inst.(*ir.InstCall)
+					if !ok {
+						continue
+					}
+					if callee, ok := call.Callee.(*ir.Function); ok && !generated[callee.Name()] {
+						// Direct call of external function or entry point.
+						continue
+					}
+					call.CallingConv = cc
+				}
+			}
+		}
+	}
+}
+
+// isEntryPoint reports whether the given function is invoked by the runtime;
+// i.e. the main function or a package initializer.
+func isEntryPoint(f *ir.Function) bool {
+	name := f.Name()
+	return name == "main" || strings.HasSuffix(name, ".init")
+}
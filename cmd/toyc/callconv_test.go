@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestSetCallingConv(t *testing.T) {
+	// Module of package foo, calling a function of package bar.
+	foo := &module{id: "example.com/foo", Module: ir.NewModule()}
+	barDecl := foo.NewFunc("example.com/bar.G", types.Void)
+	ext := foo.NewFunc("toy.alloc", types.NewPointer(types.I8), ir.NewParam("size", types.I64))
+	f := foo.NewFunc("F", types.Void)
+	entry := f.NewBlock("")
+	callBar := entry.NewCall(barDecl)
+	callExt := entry.NewCall(ext, constant.NewInt(types.I64, 8))
+	entry.NewRet(nil)
+	mainFunc := foo.NewFunc("main", types.Void)
+	mainEntry := mainFunc.NewBlock("")
+	callF := mainEntry.NewCall(f)
+	mainEntry.NewRet(nil)
+	// Module of package bar.
+	bar := &module{id: "example.com/bar", Module: ir.NewModule()}
+	g := bar.NewFunc("example.com/bar.G", types.Void)
+	g.NewBlock("").NewRet(nil)
+	cc, err := callingConv("fastcc")
+	if err != nil {
+		t.Fatalf("unable to get calling convention; %+v", err)
+	}
+	setCallingConv([]*module{foo, bar}, cc)
+	// Generated functions and their call sites, including functions defined by
+	// other compiled modules.
+	for _, f := range []*ir.Function{f, g, barDecl} {
+		if f.CallingConv != enum.CallingConvFast {
+			t.Errorf("calling convention mismatch of function %q; expected %v, got %v", f.Name(), enum.CallingConvFast, f.CallingConv)
+		}
+	}
+	for _, call := range []*ir.InstCall{callBar, callF} {
+		if call.CallingConv != enum.CallingConvFast {
+			t.Errorf("calling convention mismatch of call `%v`; expected %v, got %v", call.LLString(), enum.CallingConvFast, call.CallingConv)
+		}
+	}
+	// External functions and entry points retain the C calling convention.
+	for _, f := range []*ir.Function{ext, mainFunc} {
+		if f.CallingConv != enum.CallingConvNone {
+			t.Errorf("calling convention mismatch of function %q; expected C calling convention, got %v", f.Name(), f.CallingConv)
+		}
+	}
+	if callExt.CallingConv != enum.CallingConvNone {
+		t.Errorf("calling convention mismatch of call `%v`; expected C calling convention, got %v", callExt.LLString(), callExt.CallingConv)
+	}
+	if _, err := callingConv("stdcall"); err == nil {
+		t.Errorf("expected error for invalid calling convention %q", "stdcall")
+	}
+}
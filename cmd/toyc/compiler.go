@@ -10,9 +10,21 @@ import (
 // during compilation.
 type compiler struct {
 	// Compiled LLVM IR modules.
-	modules []*ir.Module
+	modules []*module
 	// List of errors encountered during compilation.
 	errs []error
+	// Emit bounds checks of index and slice expressions.
+	boundsCheck bool
+	// Emit nil checks of pointer dereferences.
+	nilCheck bool
+}
+
+// module is a compiled LLVM IR module.
+type module struct {
+	// Module identifier; the import path of the compiled Go package.
+	id string
+	// LLVM IR module.
+	*ir.Module
 }
 
 // newCompiler returns a new compiler for tracking the state of compilation.
@@ -38,6 +50,11 @@ func (c *compiler) post(pkg *packages.Package) {
 	}
 	// Lower Go package to an LLVM IR module.
 	gen := lower.NewGenerator(eh, pkg)
-	m := gen.Lower()
+	gen.BoundsCheck = c.boundsCheck
+	gen.NilCheck = c.nilCheck
+	m := &module{
+		id:     pkg.PkgPath,
+		Module: gen.Lower(),
+	}
 	c.modules = append(c.modules, m)
 }
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/pkg/errors"
+)
+
+// addGlobalCtor registers the package initializer of the given compiled module
+// in @llvm.global_ctors, as requested by the -global-ctors flag, so that the
+// package is initialized on program start-up (or when loading a shared library)
+// without an explicit call from the entry point.
+//
+//	@llvm.global_ctors = appending global [1 x { i32, void ()*, i8* }] [{ i32, void ()*, i8* } { i32 65535, void ()* @pkg.init, i8* null }]
+//
+// Package initializers initialize imported packages first and run at most
+// once, thus the order in which the constructors of several modules run is
+// irrelevant.
+func addGlobalCtor(m *module) error {
+	initName := m.id + ".init"
+	var init *ir.Function
+	for _, f := range m.Funcs {
+		if f.Name() == initName && len(f.Blocks) > 0 {
+			init = f
+			break
+		}
+	}
+	if init == nil {
+		return errors.Errorf("unable to locate package initializer %q of module %q", initName, m.id)
+	}
+	// Default priority of constructors.
+	const priority = 65535
+	ctor := constant.NewStruct(
+		constant.NewInt(types.I32, priority),
+		init,
+		// Constructors are not associated with any global variable.
+		constant.NewNull(types.NewPointer(types.I8)),
+	)
+	ctors := m.NewGlobalDef("llvm.global_ctors", constant.NewArray(ctor))
+	ctors.Linkage = enum.LinkageAppending
+	return nil
+}
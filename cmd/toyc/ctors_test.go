@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestAddGlobalCtor(t *testing.T) {
+	m := &module{id: "example.com/foo", Module: ir.NewModule()}
+	init := m.NewFunc("example.com/foo.init", types.Void)
+	init.NewBlock("").NewRet(nil)
+	if err := addGlobalCtor(m); err != nil {
+		t.Fatalf("unable to add global constructor; %+v", err)
+	}
+	if len(m.Globals) != 1 {
+		t.Fatalf("number of global variables mismatch; expected 1, got %d", len(m.Globals))
+	}
+	ctors := m.Globals[0]
+	if ctors.Name() != "llvm.global_ctors" {
+		t.Errorf("global variable name mismatch; expected %q, got %q", "llvm.global_ctors", ctors.Name())
+	}
+	if ctors.Linkage != enum.LinkageAppending {
+		t.Errorf("linkage mismatch of %q; expected %v, got %v", ctors.Name(), enum.LinkageAppending, ctors.Linkage)
+	}
+	// The package initializer is registered with the default priority.
+	array, ok := ctors.Init.(*constant.Array)
+	if !ok || len(array.Elems) != 1 {
+		t.Fatalf("invalid initializer of %q; expected array of one constructor, got %v", ctors.Name(), ctors.Init)
+	}
+	ctor, ok := array.Elems[0].(*constant.Struct)
+	if !ok || len(ctor.Fields) != 3 {
+		t.Fatalf("invalid constructor; expected struct of three fields, got %v", array.Elems[0])
+	}
+	if ctor.Fields[1] != init {
+		t.Errorf("constructor function mismatch; expected %q, got %v", init.Name(), ctor.Fields[1])
+	}
+	// Modules without package initializer definition.
+	m = &module{id: "example.com/bar", Module: ir.NewModule()}
+	m.NewFunc("example.com/bar.init", types.Void)
+	if err := addGlobalCtor(m); err == nil {
+		t.Errorf("expected error for missing package initializer of module %q", m.id)
+	}
+}
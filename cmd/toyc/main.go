@@ -8,7 +8,9 @@ import (
 	"log"
 	"os"
 
+	"github.com/llir/llvm/ir"
 	"github.com/mewkiz/pkg/term"
+	"github.com/mewspring/toy/opt"
 	"golang.org/x/tools/go/packages"
 )
 
@@ -30,8 +32,72 @@ Usage: toyc [OPTION]... [packages]
 }
 
 func main() {
+	// Command line flags.
+	var (
+		// splitDir specifies the output directory of per-function LLVM IR
+		// assembly files.
+		splitDir string
+		// stackProtector specifies the stack protector mode of generated
+		// functions.
+		stackProtector string
+		// framePointer specifies whether to retain frame pointers in generated
+		// functions.
+		framePointer bool
+		// pic specifies whether to generate position-independent code.
+		pic bool
+		// boundsCheck specifies whether to emit bounds checks of index and
+		// slice expressions.
+		boundsCheck bool
+		// nilCheck specifies whether to emit nil checks of pointer dereferences.
+		nilCheck bool
+		// march specifies the target CPU and features of generated functions.
+		march string
+		// dse specifies whether to eliminate dead stores to stack memory.
+		dse bool
+		// globalCtors specifies whether to register package initializers in
+		// @llvm.global_ctors.
+		globalCtors bool
+		// foldShiftMask specifies whether to fold bit-field extractions.
+		foldShiftMask bool
+		// definedOnly specifies whether to omit unreferenced external
+		// declarations.
+		definedOnly bool
+		// callConv specifies the calling convention of generated functions.
+		callConv string
+	)
+	flag.StringVar(&splitDir, "split-functions", "", "output directory of per-function LLVM IR assembly files")
+	flag.StringVar(&stackProtector, "stack-protector", "", "stack protector mode of generated functions (ssp, strong or all)")
+	flag.BoolVar(&framePointer, "frame-pointer", false, "retain frame pointers in generated functions")
+	flag.BoolVar(&pic, "pic", false, "generate position-independent code")
+	flag.BoolVar(&boundsCheck, "bounds-check", true, "emit bounds checks of index and slice expressions")
+	flag.BoolVar(&nilCheck, "nil-check", false, "emit nil checks of pointer dereferences")
+	flag.StringVar(&march, "march", "", "target CPU and features of generated functions (e.g. skylake,+avx2,-sse4a)")
+	flag.BoolVar(&dse, "dse", false, "eliminate dead stores to stack memory")
+	flag.BoolVar(&globalCtors, "global-ctors", false, "register package initializers in @llvm.global_ctors")
+	flag.BoolVar(&foldShiftMask, "fold-shift-mask", false, "fold shift-and-mask bit-field extractions")
+	flag.BoolVar(&definedOnly, "emit-defined-only", false, "omit unreferenced external declarations")
+	flag.StringVar(&callConv, "callconv", "", "calling convention of generated functions and their call sites (e.g. fastcc)")
 	flag.Usage = usage
 	flag.Parse()
+	// Function attributes added to each generated function.
+	var funcAttrs []ir.FuncAttribute
+	if attr, ok, err := stackProtectorAttr(stackProtector); err != nil {
+		log.Fatalf("%+v", err)
+	} else if ok {
+		funcAttrs = append(funcAttrs, attr)
+	}
+	if framePointer {
+		funcAttrs = append(funcAttrs, framePointerAttr)
+	}
+	attrs, err := targetAttrs(march)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	funcAttrs = append(funcAttrs, attrs...)
+	cc, err := callingConv(callConv)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
 
 	// Pass command-line arguments uninterpreted to packages.Load so that it can
 	// interpret them according to the conventions of the underlying build
@@ -46,6 +112,8 @@ func main() {
 	}
 	// Compile packages.
 	c := newCompiler()
+	c.boundsCheck = boundsCheck
+	c.nilCheck = nilCheck
 	packages.Visit(pkgs, c.pre, c.post)
 	switch len(c.errs) {
 	case 0:
@@ -60,8 +128,42 @@ func main() {
 		}
 		log.Fatal(buf.String())
 	}
+	// The calling convention is set across modules, as functions may be called
+	// from other packages.
+	setCallingConv(c.modules, cc)
 	// Print compiled LLVM IR modules.
 	for _, m := range c.modules {
+		if dse {
+			for _, f := range m.Funcs {
+				opt.DeadStoreElim(f)
+			}
+		}
+		if foldShiftMask {
+			for _, f := range m.Funcs {
+				opt.FoldShiftMask(f)
+			}
+		}
+		if definedOnly {
+			pruneDecls(m.Module)
+		}
+		addFuncAttrs(m.Module, funcAttrs...)
+		if pic {
+			markPIC(m.Module)
+		}
+		if globalCtors {
+			if err := addGlobalCtor(m); err != nil {
+				log.Fatalf("%+v", err)
+			}
+		}
+		fmt.Printf("; ModuleID = '%s'\n", m.id)
 		fmt.Println(m.String())
 	}
+	// Write each compiled function to a separate LLVM IR assembly file.
+	if len(splitDir) > 0 {
+		for _, m := range c.modules {
+			if err := writeSplitFuncs(splitDir, m.Module); err != nil {
+				log.Fatalf("%+v", err)
+			}
+		}
+	}
 }
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/metadata"
+	"github.com/llir/llvm/ir/types"
+)
+
+// markPIC marks the given LLVM IR module as position-independent code, as
+// requested by the -pic flag.
+//
+// The PIC level is recorded in the module flags, and global variables and
+// functions visible outside of the module are marked as preemptible, so that
+// they may be interposed at load time and are accessed through the global
+// offset table. Module-local entities remain DSO local.
+func markPIC(m *ir.Module) {
+	// !llvm.module.flags = !{!0}
+	// !0 = !{i32 7, !"PIC Level", i32 2}
+	const (
+		// Module flag behaviour; use the maximum value when linking modules.
+		behaviorMax = 7
+		// PIC level 2 (BigPIC), as with -fPIC; i.e. no limit on the size of the
+		// global offset table (as opposed to level 1, -fpic).
+		bigPIC = 2
+	)
+	flag := &metadata.Tuple{
+		MetadataID: int64(len(m.MetadataDefs)),
+		Fields: []metadata.Field{
+			constant.NewInt(types.I32, behaviorMax),
+			&metadata.String{Value: "PIC Level"},
+			constant.NewInt(types.I32, bigPIC),
+		},
+	}
+	m.MetadataDefs = append(m.MetadataDefs, flag)
+	if m.NamedMetadataDefs == nil {
+		m.NamedMetadataDefs = make(map[string]*metadata.NamedDef)
+	}
+	const flagsName = "llvm.module.flags"
+	flags, ok := m.NamedMetadataDefs[flagsName]
+	if !ok {
+		flags = &metadata.NamedDef{Name: flagsName}
+		m.NamedMetadataDefs[flagsName] = flags
+	}
+	flags.Nodes = append(flags.Nodes, flag)
+	// Preemption specifiers.
+	for _, g := range m.Globals {
+		g.Preemption = picPreemption(g.Linkage)
+	}
+	for _, f := range m.Funcs {
+		f.Preemption = picPreemption(f.Linkage)
+	}
+}
+
+// picPreemption returns the preemption specifier of global variables and
+// functions with the given linkage in position-independent code.
+func picPreemption(linkage enum.Linkage) enum.Preemption {
+	switch linkage {
+	case enum.LinkagePrivate, enum.LinkageInternal:
+		// Not visible outside of the module.
+		return enum.PreemptionDSOLocal
+	default:
+		return enum.PreemptionDSOPreemptable
+	}
+}
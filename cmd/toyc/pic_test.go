@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestMarkPIC(t *testing.T) {
+	m := ir.NewModule()
+	exported := m.NewGlobalDef("x", constant.NewInt(types.I64, 0))
+	local := m.NewGlobalDef("y", constant.NewInt(types.I64, 0))
+	local.Linkage = enum.LinkageInternal
+	f := m.NewFunc("f", types.Void)
+	f.NewBlock("").NewRet(nil)
+	markPIC(m)
+	// Entities visible outside of the module may be interposed.
+	if exported.Preemption != enum.PreemptionDSOPreemptable {
+		t.Errorf("preemption mismatch of global %q; expected %v, got %v", exported.Name(), enum.PreemptionDSOPreemptable, exported.Preemption)
+	}
+	if f.Preemption != enum.PreemptionDSOPreemptable {
+		t.Errorf("preemption mismatch of function %q; expected %v, got %v", f.Name(), enum.PreemptionDSOPreemptable, f.Preemption)
+	}
+	if local.Preemption != enum.PreemptionDSOLocal {
+		t.Errorf("preemption mismatch of global %q; expected %v, got %v", local.Name(), enum.PreemptionDSOLocal, local.Preemption)
+	}
+	// PIC level recorded in the module flags.
+	flags, ok := m.NamedMetadataDefs["llvm.module.flags"]
+	if !ok {
+		t.Fatalf("unable to locate module flags")
+	}
+	if len(flags.Nodes) != 1 {
+		t.Errorf("number of module flags mismatch; expected 1, got %d", len(flags.Nodes))
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/value"
+)
+
+// pruneDecls removes external function and global variable declarations which
+// are not referenced by any definition of the LLVM IR module, as requested by
+// the -emit-defined-only flag.
+func pruneDecls(m *ir.Module) {
+	refs := make(map[value.Value]bool)
+	for _, g := range m.Globals {
+		if g.Init != nil {
+			markRefs(refs, g.Init)
+		}
+	}
+	for _, f := range m.Funcs {
+		for _, block := range f.Blocks {
+			for _, inst := range block.Insts {
+				if user, ok := inst.(value.User); ok {
+					for _, op := range user.Operands() {
+						markRefs(refs, *op)
+					}
+				}
+			}
+			if user, ok := block.Term.(value.User); ok {
+				for _, op := range user.Operands() {
+					markRefs(refs, *op)
+				}
+			}
+		}
+	}
+	globals := m.Globals[:0]
+	for _, g := range m.Globals {
+		if g.Init != nil || refs[g] {
+			globals = append(globals, g)
+		}
+	}
+	m.Globals = globals
+	funcs := m.Funcs[:0]
+	for _, f := range m.Funcs {
+		if len(f.Blocks) > 0 || refs[f] {
+			funcs = append(funcs, f)
+		}
+	}
+	m.Funcs = funcs
+}
+
+// markRefs marks the global variables and functions referred to by the given
+// value (including through constant aggregates and constant expressions) as
+// referenced.
+func markRefs(refs map[value.Value]bool, v value.Value) {
+	switch v := v.(type) {
+	case *ir.Global, *ir.Function:
+		refs[v] = true
+	case *constant.Struct:
+		for _, field := range v.Fields {
+			markRefs(refs, field)
+		}
+	case *constant.Array:
+		for _, elem := range v.Elems {
+			markRefs(refs, elem)
+		}
+	case *constant.ExprBitCast:
+		markRefs(refs, v.From)
+	case *constant.ExprPtrToInt:
+		markRefs(refs, v.From)
+	case *constant.ExprGetElementPtr:
+		markRefs(refs, v.Src)
+		for _, index := range v.Indices {
+			markRefs(refs, index)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestPruneDecls(t *testing.T) {
+	m := ir.NewModule()
+	used := m.NewFunc("used", types.Void)
+	unused := m.NewFunc("unused", types.Void)
+	usedGlobal := m.NewGlobalDecl("x", types.I64)
+	unusedGlobal := m.NewGlobalDecl("y", types.I64)
+	f := m.NewFunc("f", types.I64)
+	entry := f.NewBlock("")
+	entry.NewCall(used)
+	entry.NewRet(entry.NewLoad(usedGlobal))
+	pruneDecls(m)
+	// Definitions and referenced declarations are retained.
+	if !hasFunc(m, f) || !hasFunc(m, used) {
+		t.Errorf("referenced function removed; got %v", m.Funcs)
+	}
+	if hasFunc(m, unused) {
+		t.Errorf("unused function declaration %q not removed", unused.Name())
+	}
+	if !hasGlobal(m, usedGlobal) {
+		t.Errorf("referenced global variable declaration %q removed", usedGlobal.Name())
+	}
+	if hasGlobal(m, unusedGlobal) {
+		t.Errorf("unused global variable declaration %q not removed", unusedGlobal.Name())
+	}
+}
+
+// hasFunc reports whether the given module contains the function.
+func hasFunc(m *ir.Module, f *ir.Function) bool {
+	for _, g := range m.Funcs {
+		if g == f {
+			return true
+		}
+	}
+	return false
+}
+
+// hasGlobal reports whether the given module contains the global variable.
+func hasGlobal(m *ir.Module, g *ir.Global) bool {
+	for _, h := range m.Globals {
+		if h == g {
+			return true
+		}
+	}
+	return false
+}
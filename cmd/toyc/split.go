@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/llir/llvm/ir"
+	"github.com/pkg/errors"
+)
+
+// writeSplitFuncs writes each function definition of the given LLVM IR module
+// to a separate LLVM IR assembly file (e.g. "DIR/foo.ll") in the output
+// directory. Each file is a self-contained module which includes the type
+// definitions and global variables of m, and declarations of the remaining
+// functions, so that it may be fed to llc in isolation.
+func writeSplitFuncs(dir string, m *ir.Module) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.WithStack(err)
+	}
+	// File names in use, to disambiguate function names which map to the same
+	// file name.
+	used := make(map[string]bool)
+	for _, f := range m.Funcs {
+		if len(f.Blocks) == 0 {
+			// Skip function declarations.
+			continue
+		}
+		fm := ir.NewModule()
+		fm.TypeDefs = m.TypeDefs
+		fm.Globals = m.Globals
+		for _, g := range m.Funcs {
+			if g != f {
+				// Declaration of other function. The parameters are not shared
+				// with the definition, as their IDs belong to its body.
+				var params []*ir.Param
+				for _, p := range g.Params {
+					param := ir.NewParam(p.Name(), p.Typ)
+					param.Attrs = p.Attrs
+					params = append(params, param)
+				}
+				decl := ir.NewFunc(g.Name(), g.Sig.RetType, params...)
+				decl.Sig.Variadic = g.Sig.Variadic
+				// Calls must agree with the callee on calling convention.
+				decl.CallingConv = g.CallingConv
+				decl.Preemption = g.Preemption
+				decl.ReturnAttrs = g.ReturnAttrs
+				decl.FuncAttrs = g.FuncAttrs
+				g = decl
+			}
+			fm.Funcs = append(fm.Funcs, g)
+		}
+		llPath := filepath.Join(dir, funcFileName(f.Name(), used)+".ll")
+		dbg.Printf("creating %q", llPath)
+		if err := os.WriteFile(llPath, []byte(fm.String()), 0644); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// funcFileName returns a file name (without extension) for the given function
+// name, which is unique among the given file names in use.
+//
+// Characters of function names which are not safe in file names (e.g. '/' of
+// "example.com/foo.init", '*' and '$' of "*T.M$iface", '[' and ']' of
+// "Max[int]") are replaced with '_'.
+func funcFileName(name string, used map[string]bool) string {
+	base := strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+			return r
+		case r == '.' || r == '-' || r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+	if strings.HasPrefix(base, ".") {
+		// Avoid hidden files (and the "." and ".." directory entries).
+		base = "_" + base[1:]
+	}
+	fileName := base
+	for i := 1; used[fileName]; i++ {
+		fileName = fmt.Sprintf("%s.%d", base, i)
+	}
+	used[fileName] = true
+	return fileName
+}
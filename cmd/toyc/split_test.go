@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestWriteSplitFuncs(t *testing.T) {
+	m := ir.NewModule()
+	for _, name := range []string{"example.com/foo.init", "Max[int]"} {
+		f := m.NewFunc(name, types.Void)
+		f.NewBlock("").NewRet(nil)
+	}
+	// The calling convention and attributes of functions carry over to their
+	// declarations.
+	f := m.Funcs[1]
+	f.CallingConv = enum.CallingConvFast
+	f.FuncAttrs = append(f.FuncAttrs, enum.FuncAttrNoUnwind)
+	// Function declarations are not written to separate files.
+	m.NewFunc("toy.alloc", types.NewPointer(types.I8), ir.NewParam("", types.I64))
+	dir := t.TempDir()
+	if err := writeSplitFuncs(dir, m); err != nil {
+		t.Fatalf("unable to write split functions; %+v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, entry := range entries {
+		got = append(got, entry.Name())
+	}
+	sort.Strings(got)
+	want := []string{"Max_int_.ll", "example.com_foo.init.ll"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("file names mismatch; expected %q, got %q", want, got)
+	}
+	for _, name := range want {
+		buf, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Each file defines a single function, and declares the others.
+		if n := strings.Count(string(buf), "define "); n != 1 {
+			t.Errorf("%s: number of function definitions mismatch; expected 1, got %d", name, n)
+		}
+		if n := strings.Count(string(buf), "declare "); n != 2 {
+			t.Errorf("%s: number of function declarations mismatch; expected 2, got %d", name, n)
+		}
+	}
+	buf, err := os.ReadFile(filepath.Join(dir, "example.com_foo.init.ll"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const wantDecl = `declare fastcc void @"Max[int]"() nounwind`
+	if !strings.Contains(string(buf), wantDecl) {
+		t.Errorf("unable to locate %q in:\n%s", wantDecl, buf)
+	}
+}
+
+func TestFuncFileName(t *testing.T) {
+	golden := []struct {
+		name string
+		want string
+	}{
+		{name: "main", want: "main"},
+		{name: "example.com/foo.init", want: "example.com_foo.init"},
+		{name: "*T.M$iface", want: "_T.M_iface"},
+		{name: "Max[int]", want: "Max_int_"},
+		// Disambiguate names which map to the same file name.
+		{name: "Max(int)", want: "Max_int_.1"},
+		{name: ".str.0", want: "_str.0"},
+	}
+	used := make(map[string]bool)
+	for _, g := range golden {
+		got := funcFileName(g.name, used)
+		if got != g.want {
+			t.Errorf("%q: file name mismatch; expected %q, got %q", g.name, g.want, got)
+		}
+	}
+}
@@ -86,10 +86,21 @@ func NewAggregateRet(b Builder, vs ...value.Value) *ir.TermRet {
 		fieldTypes[i] = field.Type()
 	}
 	t := types.NewStruct(fieldTypes...)
-	aggMem := b.NewAlloca(t)
-	var agg value.Value = b.NewLoad(aggMem)
+	agg := NewAggregate(b, t, vs...)
+	return b.NewRet(agg)
+}
+
+// NewAggregate inserts instructions into the current basic block of the builder
+// to create a new aggregate value of the given type containing the given
+// values.
+//
+// The aggregate value is built by inserting the values into an undefined value
+// of the given type, rather than through stack memory, as the current basic
+// block may be part of a loop.
+func NewAggregate(b Builder, t types.Type, vs ...value.Value) value.Value {
+	var agg value.Value = constant.NewUndef(t)
 	for i, field := range vs {
 		agg = b.NewInsertValue(agg, field, uint64(i))
 	}
-	return ir.NewRet(agg)
+	return agg
 }
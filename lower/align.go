@@ -0,0 +1,154 @@
+package lower
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// newLoad emits a load from the given source memory address to f, with the ABI
+// alignment of the loaded type.
+func (fgen *funcGen) newLoad(src value.Value) *ir.InstLoad {
+	inst := fgen.cur.NewLoad(src)
+	inst.Align = memAlign(src, inst.Type())
+	return inst
+}
+
+// newStore emits a store of the given value to the destination memory address
+// to f, with the ABI alignment of the stored type.
+func (fgen *funcGen) newStore(v, dst value.Value) *ir.InstStore {
+	inst := fgen.cur.NewStore(v, dst)
+	inst.Align = memAlign(dst, v.Type())
+	return inst
+}
+
+// memAlign returns the alignment in bytes of memory accesses of the given type
+// through the given address. Fields of packed structures are not padded, and
+// are thus accessed with an alignment of one byte.
+func memAlign(mem value.Value, t types.Type) ir.Align {
+	if gep, ok := mem.(*ir.InstGetElementPtr); ok {
+		if st, ok := gep.ElemType.(*types.StructType); ok && st.Packed {
+			return 1
+		}
+	}
+	return alignOf(t)
+}
+
+// alignOf returns the ABI alignment in bytes of the given LLVM IR type on the
+// 64-bit target architecture (e.g. x86-64).
+func alignOf(t types.Type) ir.Align {
+	const wordAlign = cpuWordSize / 8
+	switch t := t.(type) {
+	case *types.IntType:
+		// Integers are aligned to their size, rounded up to the next power of
+		// two, and at most to the word size (e.g. i1 and i8 to 1, i24 to 4).
+		return minAlign(pow2Align((t.BitSize+7)/8), wordAlign)
+	case *types.FloatType:
+		switch t.Kind {
+		case types.FloatKindHalf:
+			return 2
+		case types.FloatKindFloat:
+			return 4
+		case types.FloatKindDouble:
+			return 8
+		default:
+			// x86_fp80, fp128 and ppc_fp128.
+			return 16
+		}
+	case *types.PointerType:
+		return wordAlign
+	case *types.VectorType:
+		// Vectors are aligned to their size, rounded up to the next power of
+		// two.
+		elemSize := uint64(alignOf(t.ElemType))
+		if e, ok := t.ElemType.(*types.IntType); ok {
+			elemSize = (e.BitSize + 7) / 8
+		}
+		return pow2Align(t.Len * elemSize)
+	case *types.ArrayType:
+		return alignOf(t.ElemType)
+	case *types.StructType:
+		if t.Packed || t.Opaque {
+			return 1
+		}
+		// Structures are aligned to the largest alignment of their fields.
+		var align ir.Align = 1
+		for _, field := range t.Fields {
+			if a := alignOf(field); a > align {
+				align = a
+			}
+		}
+		return align
+	default:
+		return 1
+	}
+}
+
+// abiSize returns the ABI size in bytes of the given LLVM IR type on the 64-bit
+// target architecture (e.g. x86-64), including padding to its alignment.
+//
+// The size is computed at compile time, for decisions made by the compiler
+// (e.g. whether to copy a struct using memcpy). Sizes passed to the runtime
+// are instead emitted using sizeof, as constant expressions which are
+// independent of the target data layout.
+func abiSize(t types.Type) uint64 {
+	switch t := t.(type) {
+	case *types.IntType:
+		return alignTo((t.BitSize+7)/8, alignOf(t))
+	case *types.FloatType:
+		switch t.Kind {
+		case types.FloatKindHalf:
+			return 2
+		case types.FloatKindFloat:
+			return 4
+		case types.FloatKindDouble:
+			return 8
+		default:
+			// x86_fp80, fp128 and ppc_fp128.
+			return 16
+		}
+	case *types.PointerType:
+		return cpuWordSize / 8
+	case *types.VectorType:
+		return alignTo(t.Len*abiSize(t.ElemType), alignOf(t))
+	case *types.ArrayType:
+		return t.Len * abiSize(t.ElemType)
+	case *types.StructType:
+		// Fields are laid out in order, each at an offset aligned to the
+		// alignment of the field, and the structure is padded to its alignment.
+		var size uint64
+		for _, field := range t.Fields {
+			if !t.Packed {
+				size = alignTo(size, alignOf(field))
+			}
+			size += abiSize(field)
+		}
+		return alignTo(size, alignOf(t))
+	default:
+		return 0
+	}
+}
+
+// alignTo returns the given size in bytes rounded up to the given alignment.
+func alignTo(size uint64, align ir.Align) uint64 {
+	a := uint64(align)
+	return (size + a - 1) / a * a
+}
+
+// pow2Align returns the given size in bytes rounded up to the next power of
+// two, as an alignment.
+func pow2Align(size uint64) ir.Align {
+	var align ir.Align = 1
+	for uint64(align) < size {
+		align <<= 1
+	}
+	return align
+}
+
+// minAlign returns the smaller of the two alignments.
+func minAlign(a, b ir.Align) ir.Align {
+	if a < b {
+		return a
+	}
+	return b
+}
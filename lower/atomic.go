@@ -0,0 +1,85 @@
+package lower
+
+import (
+	"fmt"
+	"go/ast"
+	gotypes "go/types"
+	"strings"
+
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/value"
+	"github.com/pkg/errors"
+)
+
+// atomicFuncOf returns the function of the sync/atomic package referred to by
+// the given callee expression. The boolean return value indicates success.
+//
+// Methods of the atomic types (e.g. atomic.Int64.Add) are not included, as they
+// are implemented in Go in terms of the functions of the package.
+func (gen *Generator) atomicFuncOf(goCallee ast.Expr) (*gotypes.Func, bool) {
+	var goIdent *ast.Ident
+	switch goCallee := unparen(goCallee).(type) {
+	case *ast.Ident:
+		goIdent = goCallee
+	case *ast.SelectorExpr:
+		goIdent = goCallee.Sel
+	default:
+		return nil, false
+	}
+	fn, ok := gen.pkg.TypesInfo.Uses[goIdent].(*gotypes.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != "sync/atomic" {
+		return nil, false
+	}
+	if fn.Type().(*gotypes.Signature).Recv() != nil {
+		return nil, false
+	}
+	return fn, true
+}
+
+// lowerAtomicCallExpr lowers the Go call expression of the given sync/atomic
+// function to LLVM IR, emitting to f. The operations are lowered to atomic
+// instructions with sequentially consistent ordering, as guaranteed by the Go
+// memory model.
+func (fgen *funcGen) lowerAtomicCallExpr(fn *gotypes.Func, goCallExpr *ast.CallExpr) (value.Value, error) {
+	args, err := fgen.lowerCallArgs(goCallExpr)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	const ordering = enum.AtomicOrderingSeqCst
+	name := fn.Name()
+	switch {
+	case strings.HasPrefix(name, "Add"):
+		// func AddInt64(addr *int64, delta int64) (new int64)
+		addr, delta := args[0], args[1]
+		old := fgen.cur.NewAtomicRMW(enum.AtomicOpAdd, addr, delta, ordering)
+		return fgen.cur.NewAdd(old, delta), nil
+	case strings.HasPrefix(name, "And"):
+		// func AndInt64(addr *int64, mask int64) (old int64)
+		return fgen.cur.NewAtomicRMW(enum.AtomicOpAnd, args[0], args[1], ordering), nil
+	case strings.HasPrefix(name, "Or"):
+		// func OrInt64(addr *int64, mask int64) (old int64)
+		return fgen.cur.NewAtomicRMW(enum.AtomicOpOr, args[0], args[1], ordering), nil
+	case strings.HasPrefix(name, "Swap"):
+		// func SwapInt64(addr *int64, new int64) (old int64)
+		return fgen.cur.NewAtomicRMW(enum.AtomicOpXChg, args[0], args[1], ordering), nil
+	case strings.HasPrefix(name, "CompareAndSwap"):
+		// func CompareAndSwapInt64(addr *int64, old, new int64) (swapped bool)
+		result := fgen.cur.NewCmpXchg(args[0], args[1], args[2], ordering, ordering)
+		return fgen.cur.NewExtractValue(result, 1), nil
+	case strings.HasPrefix(name, "Load"):
+		// func LoadInt64(addr *int64) (val int64)
+		inst := fgen.newLoad(args[0])
+		inst.Atomic = true
+		inst.Ordering = ordering
+		return inst, nil
+	case strings.HasPrefix(name, "Store"):
+		// func StoreInt64(addr *int64, val int64)
+		inst := fgen.newStore(args[1], args[0])
+		inst.Atomic = true
+		inst.Ordering = ordering
+		// No result.
+		return nil, nil
+	default:
+		panic(fmt.Errorf("support for sync/atomic function %q not yet implemented", name))
+	}
+}
@@ -0,0 +1,28 @@
+package lower
+
+import "testing"
+
+func TestAtomicAdd(t *testing.T) {
+	const src = `package p
+
+import "sync/atomic"
+
+var x int64
+
+func inc() int64 {
+	return atomic.AddInt64(&x, 1)
+}
+
+func cas(p *int64) bool {
+	return atomic.CompareAndSwapInt64(p, 1, 2)
+}
+`
+	module := lowerSource(t, src)
+	// sync/atomic functions are lowered to atomic instructions rather than
+	// calls to declared functions.
+	inc := mustFuncDef(t, module, "inc")
+	wantIR(t, inc, "atomicrmw add i64* @x, i64 1 seq_cst")
+	rejectIR(t, inc, "call ")
+	wantIR(t, mustFuncDef(t, module, "cas"), "cmpxchg i64* ", "seq_cst seq_cst")
+	rejectIR(t, module, "AddInt64", "CompareAndSwapInt64")
+}
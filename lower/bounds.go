@@ -0,0 +1,119 @@
+package lower
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// checkIndex emits a bounds check of the given 64-bit index against the length
+// of the indexed array, slice or string to f, unless bounds checking has been
+// disabled. The runtime panics if the index is out of range.
+//
+//	if uint64(index) >= uint64(length) {
+//	   toy.panicindex(index, length)
+//	}
+func (fgen *funcGen) checkIndex(index, length value.Value) {
+	if !fgen.gen.BoundsCheck || inRange(index, length) {
+		return
+	}
+	// The unsigned comparison also reports negative indices as out of range.
+	outOfRange := fgen.cur.NewICmp(enum.IPredUGE, index, length)
+	panicindex := fgen.gen.runtimeFunc("panicindex", types.Void, types.I64, types.I64)
+	fgen.emitCheck(outOfRange, func() {
+		fgen.cur.NewCall(panicindex, index, length)
+	})
+}
+
+// inRange reports whether the given index is known to be in range of the given
+// length at compile time (e.g. constant index of array).
+func inRange(index, length value.Value) bool {
+	i, ok := index.(*constant.Int)
+	if !ok {
+		return false
+	}
+	n, ok := length.(*constant.Int)
+	if !ok {
+		return false
+	}
+	return i.X.Sign() >= 0 && i.X.Cmp(n.X) < 0
+}
+
+// checkSlice emits a bounds check of the given 64-bit low, high and max
+// indices of a slice expression against the capacity of the sliced operand to
+// f, unless bounds checking has been disabled. The runtime panics unless
+// 0 <= low <= high <= max <= capacity.
+//
+//	if uint64(high) > uint64(max) || uint64(max) > uint64(capacity) || uint64(low) > uint64(high) {
+//	   toy.panicslice(low, high, max, capacity)
+//	}
+func (fgen *funcGen) checkSlice(low, high, max, capacity value.Value) {
+	if !fgen.gen.BoundsCheck {
+		return
+	}
+	// The unsigned comparisons also report negative indices as out of range.
+	maxOutOfRange := fgen.cur.NewICmp(enum.IPredUGT, max, capacity)
+	highOutOfRange := fgen.cur.NewICmp(enum.IPredUGT, high, max)
+	lowOutOfRange := fgen.cur.NewICmp(enum.IPredUGT, low, high)
+	outOfRange := fgen.cur.NewOr(fgen.cur.NewOr(maxOutOfRange, highOutOfRange), lowOutOfRange)
+	panicslice := fgen.gen.runtimeFunc("panicslice", types.Void, types.I64, types.I64, types.I64, types.I64)
+	fgen.emitCheck(outOfRange, func() {
+		fgen.cur.NewCall(panicslice, low, high, max, capacity)
+	})
+}
+
+// checkNil emits a nil check of the given pointer to f before memory is
+// accessed through it, unless nil checks have been disabled or the pointer is
+// known to be non-nil. The runtime panics if the pointer is nil.
+//
+//	if ptr == nil {
+//	   toy.panicnil()
+//	}
+func (fgen *funcGen) checkNil(ptr value.Value) {
+	if !fgen.gen.NilCheck || fgen.isNonNil(ptr) {
+		return
+	}
+	null := constant.NewNull(ptr.Type().(*types.PointerType))
+	isNil := fgen.cur.NewICmp(enum.IPredEQ, ptr, null)
+	panicnil := fgen.gen.runtimeFunc("panicnil", types.Void)
+	fgen.emitCheck(isNil, func() {
+		fgen.cur.NewCall(panicnil)
+	})
+}
+
+// isNonNil reports whether the given pointer is known to be non-nil; i.e. the
+// address of stack memory, global variables or heap allocated memory, or
+// derived therefrom (e.g. address of field or array element).
+func (fgen *funcGen) isNonNil(ptr value.Value) bool {
+	switch ptr := ptr.(type) {
+	case *ir.InstAlloca, *ir.Global, *ir.Function:
+		return true
+	case *ir.InstGetElementPtr:
+		return fgen.isNonNil(ptr.Src)
+	case *ir.InstBitCast:
+		return fgen.isNonNil(ptr.From)
+	case *ir.InstCall:
+		// Heap allocation.
+		alloc, ok := fgen.gen.runtimeFuncs["toy.alloc"]
+		return ok && ptr.Callee == alloc
+	default:
+		return false
+	}
+}
+
+// emitCheck emits a runtime check to f, which invokes fail if the given
+// condition holds. The fail function emits a call to a runtime panic helper.
+// Lowering continues in the basic block following the check.
+func (fgen *funcGen) emitCheck(cond value.Value, fail func()) {
+	//failBlock := fgen.f.NewBlock("fail")
+	failBlock := fgen.f.NewBlock("")
+	//okBlock := fgen.f.NewBlock("ok")
+	okBlock := fgen.f.NewBlock("")
+	fgen.cur.NewCondBr(cond, failBlock, okBlock)
+	fgen.cur = failBlock
+	fail()
+	fgen.cur.NewUnreachable()
+	fgen.cur = okBlock
+}
@@ -0,0 +1,64 @@
+package lower
+
+import "testing"
+
+func TestIndexBoundsCheck(t *testing.T) {
+	const src = `package p
+
+func f(s []int, i int) int {
+	return s[i]
+}
+`
+	files := map[string]string{"p.go": src}
+	module, errs := lowerModule(t, files, nil)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected error during lowering; %+v", errs[0])
+	}
+	// Bounds checking enabled by default.
+	def := mustFuncDef(t, module, "f")
+	wantIR(t, def, "icmp uge i64", "call void @toy.panicindex(i64 ", "unreachable")
+	module, errs = lowerModule(t, files, func(gen *Generator) {
+		gen.BoundsCheck = false
+	})
+	if len(errs) > 0 {
+		t.Fatalf("unexpected error during lowering; %+v", errs[0])
+	}
+	rejectIR(t, module, "@toy.panicindex", "icmp uge")
+}
+
+func TestSliceBoundsCheck(t *testing.T) {
+	const src = `package p
+
+func f(s []int, lo, hi int) []int {
+	return s[lo:hi]
+}
+`
+	def := mustFuncDef(t, lowerSource(t, src), "f")
+	// 0 <= lo <= hi <= max <= cap
+	wantCount(t, def, "icmp ugt i64", 3)
+	wantCount(t, def, "or i1", 2)
+	wantIR(t, def, "call void @toy.panicslice(i64 ", "unreachable")
+}
+
+func TestNilCheck(t *testing.T) {
+	const src = `package p
+
+func f(p *int) int {
+	return *p
+}
+
+func g() int {
+	x := 1
+	return *&x
+}
+`
+	module, errs := lowerModule(t, map[string]string{"p.go": src}, func(gen *Generator) {
+		gen.NilCheck = true
+	})
+	if len(errs) > 0 {
+		t.Fatalf("unexpected error during lowering; %+v", errs[0])
+	}
+	wantIR(t, mustFuncDef(t, module, "f"), "icmp eq i64* ", "null", "call void @toy.panicnil()")
+	// The address of a local variable is known to be non-nil.
+	rejectIR(t, mustFuncDef(t, module, "g"), "@toy.panicnil")
+}
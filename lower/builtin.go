@@ -0,0 +1,492 @@
+package lower
+
+import (
+	"fmt"
+	"go/ast"
+	gotypes "go/types"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/mewspring/toy/irgen"
+	"github.com/pkg/errors"
+)
+
+// lowerBuiltinCallExpr lowers the Go call expression of the given builtin
+// function to LLVM IR, emitting to f.
+func (fgen *funcGen) lowerBuiltinCallExpr(builtin *gotypes.Builtin, goCallExpr *ast.CallExpr) (value.Value, error) {
+	switch builtin.Name() {
+	case "append":
+		return fgen.lowerAppend(goCallExpr)
+	case "cap":
+		return fgen.lowerCap(goCallExpr)
+	case "close":
+		return fgen.lowerClose(goCallExpr)
+	case "complex":
+		return fgen.lowerComplex(goCallExpr)
+	case "copy":
+		return fgen.lowerCopy(goCallExpr)
+	case "imag":
+		// func imag(c ComplexType) FloatType
+		return fgen.lowerComplexPart(goCallExpr, 1)
+	case "len":
+		return fgen.lowerLen(goCallExpr)
+	case "make":
+		return fgen.lowerMake(goCallExpr)
+	case "panic":
+		return fgen.lowerPanic(goCallExpr)
+	case "real":
+		// func real(c ComplexType) FloatType
+		return fgen.lowerComplexPart(goCallExpr, 0)
+	case "print":
+		return nil, fgen.lowerPrint(goCallExpr, false)
+	case "println":
+		return nil, fgen.lowerPrint(goCallExpr, true)
+	case "recover":
+		// The runtime returns a nil interface value (i.e. zero type descriptor
+		// and data) when not panicking.
+		f := fgen.gen.runtimeFunc("recover", fgen.gen.efaceType())
+		return fgen.cur.NewCall(f), nil
+	default:
+		panic(fmt.Errorf("support for builtin function %q not yet implemented", builtin.Name()))
+	}
+}
+
+// lowerAppend lowers the Go call expression of the builtin function append to
+// LLVM IR, emitting to f.
+//
+// The elements are stored past the length of the slice if within its capacity;
+// otherwise, a larger backing array is allocated on the heap (of twice the
+// capacity, or of the new length if larger) and the existing elements are
+// copied before storing the appended elements.
+//
+//	newlen = len + n
+//	br (newlen > cap), grow, follow
+//	grow:   newcap = max(2*cap, newlen)
+//	        newdata = alloc(newcap*size); memcpy(newdata, data, len*size)
+//	        br follow
+//	follow: data = phi [data, entry], [newdata, grow]
+//	        cap = phi [cap, entry], [newcap, grow]
+//	        store elements to data[len:newlen]
+func (fgen *funcGen) lowerAppend(goCallExpr *ast.CallExpr) (value.Value, error) {
+	// func append(slice []Type, elems ...Type) []Type
+	// func append(slice []byte, elems string...) []byte
+	goType := fgen.gen.subst(fgen.gen.pkg.TypesInfo.TypeOf(goCallExpr))
+	goSliceType := goType.Underlying().(*gotypes.Slice)
+	s, err := fgen.lowerExprUse(goCallExpr.Args[0])
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	t := s.Type()
+	data := fgen.cur.NewExtractValue(s, 0)
+	length := fgen.cur.NewExtractValue(s, 1)
+	capacity := fgen.cur.NewExtractValue(s, 2)
+	elemType := data.Type().(*types.PointerType).ElemType
+	size := sizeof(elemType)
+	// Evaluate the appended elements before growing the slice. The elements
+	// are either given individually (e.g. `append(s, x, y)`) or as a slice or
+	// string (e.g. `append(s, t...)`).
+	var (
+		elems []value.Value
+		src   value.Value
+		n     value.Value
+	)
+	if goCallExpr.Ellipsis.IsValid() {
+		x, err := fgen.lowerExprUse(goCallExpr.Args[1])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		src = fgen.cur.NewExtractValue(x, 0)
+		n = fgen.cur.NewExtractValue(x, 1)
+	} else {
+		for _, goArg := range goCallExpr.Args[1:] {
+			elem, err := fgen.lowerArg(goArg, goSliceType.Elem())
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			elems = append(elems, elem)
+		}
+		n = constant.NewInt(types.I64, int64(len(elems)))
+	}
+	newLen := fgen.cur.NewAdd(length, n)
+	full := fgen.cur.NewICmp(enum.IPredUGT, newLen, capacity)
+	prevBlock := fgen.cur
+	growBlock := fgen.f.NewBlock("")
+	followBlock := ir.NewBlock("")
+	prevBlock.NewCondBr(full, growBlock, followBlock)
+	// Grow backing array.
+	fgen.cur = growBlock
+	doubled := fgen.cur.NewMul(capacity, constant.NewInt(types.I64, 2))
+	less := fgen.cur.NewICmp(enum.IPredULT, doubled, newLen)
+	newCap := fgen.cur.NewSelect(less, newLen, doubled)
+	i8Ptr := types.NewPointer(types.I8)
+	alloc := fgen.gen.runtimeFunc("alloc", i8Ptr, types.I64)
+	newMem := fgen.cur.NewCall(alloc, fgen.cur.NewMul(newCap, size))
+	memcpy := fgen.gen.intrinsic("llvm.memcpy.p0i8.p0i8.i64", types.Void, i8Ptr, i8Ptr, types.I64, types.I1)
+	oldMem := fgen.cur.NewBitCast(data, i8Ptr)
+	fgen.cur.NewCall(memcpy, newMem, oldMem, fgen.cur.NewMul(length, size), constant.False)
+	newData := fgen.cur.NewBitCast(newMem, data.Type())
+	fgen.cur.NewBr(followBlock)
+	// Store appended elements.
+	fgen.f.Blocks = append(fgen.f.Blocks, followBlock)
+	fgen.cur = followBlock
+	data2 := fgen.cur.NewPhi(ir.NewIncoming(data, prevBlock), ir.NewIncoming(newData, growBlock))
+	cap2 := fgen.cur.NewPhi(ir.NewIncoming(capacity, prevBlock), ir.NewIncoming(newCap, growBlock))
+	dst := fgen.cur.NewGetElementPtr(data2, length)
+	if src != nil {
+		// The source may overlap the destination (e.g. `append(s[:1], s...)`).
+		memmove := fgen.gen.intrinsic("llvm.memmove.p0i8.p0i8.i64", types.Void, i8Ptr, i8Ptr, types.I64, types.I1)
+		dstPtr := fgen.cur.NewBitCast(dst, i8Ptr)
+		srcPtr := fgen.cur.NewBitCast(src, i8Ptr)
+		fgen.cur.NewCall(memmove, dstPtr, srcPtr, fgen.cur.NewMul(n, size), constant.False)
+	}
+	for i, elem := range elems {
+		ptr := fgen.cur.NewGetElementPtr(dst, constant.NewInt(types.I64, int64(i)))
+		fgen.newStore(elem, ptr)
+	}
+	return irgen.NewAggregate(fgen.cur, t, data2, newLen, cap2), nil
+}
+
+// lowerCap lowers the Go call expression of the builtin function cap to LLVM IR,
+// emitting to f. Calls with constant results (e.g. `cap(a)` for arrays a) are
+// folded by the type-checker; the remaining calls are lowered here.
+func (fgen *funcGen) lowerCap(goCallExpr *ast.CallExpr) (value.Value, error) {
+	// func cap(v Type) int
+	goArg := goCallExpr.Args[0]
+	x, err := fgen.lowerExprUse(goArg)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	goType := fgen.gen.subst(fgen.gen.pkg.TypesInfo.TypeOf(goArg))
+	if goPtrType, ok := goType.Underlying().(*gotypes.Pointer); ok {
+		// Pointer to array.
+		goType = goPtrType.Elem()
+	}
+	switch goType := goType.Underlying().(type) {
+	case *gotypes.Array:
+		// The capacity of an array is its length.
+		return constant.NewInt(types.I64, goType.Len()), nil
+	case *gotypes.Slice:
+		// Slices hold the capacity in the third field.
+		return fgen.cur.NewExtractValue(x, 2), nil
+	case *gotypes.Chan:
+		// The runtime reports the buffer capacity, and 0 for nil channels.
+		f := fgen.gen.runtimeFunc("chancap", types.I64, fgen.gen.chanType())
+		return fgen.cur.NewCall(f, x), nil
+	default:
+		return nil, errors.Errorf("invalid argument type of builtin function cap; expected array, pointer to array, slice or channel type, got %v", goType)
+	}
+}
+
+// lowerComplex lowers the Go call expression of the builtin function complex to
+// LLVM IR, emitting to f. Complex values are represented as a struct of the
+// real and imaginary parts.
+func (fgen *funcGen) lowerComplex(goCallExpr *ast.CallExpr) (value.Value, error) {
+	// func complex(r, i FloatType) ComplexType
+	goType := fgen.gen.subst(fgen.gen.pkg.TypesInfo.TypeOf(goCallExpr))
+	t, err := fgen.gen.irType(goType)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// Untyped constant operands (e.g. `complex(x, 0)`) take the floating-point
+	// type of the parts of the complex type.
+	goPartType := gotypes.Typ[gotypes.Float64]
+	if goType.Underlying().(*gotypes.Basic).Kind() == gotypes.Complex64 {
+		goPartType = gotypes.Typ[gotypes.Float32]
+	}
+	re, err := fgen.lowerArg(goCallExpr.Args[0], goPartType)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	im, err := fgen.lowerArg(goCallExpr.Args[1], goPartType)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return irgen.NewAggregate(fgen.cur, t, re, im), nil
+}
+
+// lowerComplexPart lowers the Go call expression of the builtin function real
+// (index 0) or imag (index 1) to LLVM IR, emitting to f.
+func (fgen *funcGen) lowerComplexPart(goCallExpr *ast.CallExpr, index uint64) (value.Value, error) {
+	x, err := fgen.lowerExprUse(goCallExpr.Args[0])
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return fgen.cur.NewExtractValue(x, index), nil
+}
+
+// lowerCopy lowers the Go call expression of the builtin function copy to LLVM
+// IR, emitting to f. The number of copied elements is the minimum of the length
+// of the source and destination.
+//
+// The source and destination may overlap (e.g. `copy(a[1:], a)`), thus the
+// elements are copied using memmove rather than memcpy.
+func (fgen *funcGen) lowerCopy(goCallExpr *ast.CallExpr) (value.Value, error) {
+	// func copy(dst, src []Type) int
+	// func copy(dst []byte, src string) int
+	dst, err := fgen.lowerExprUse(goCallExpr.Args[0])
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	src, err := fgen.lowerExprUse(goCallExpr.Args[1])
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	dstData := fgen.cur.NewExtractValue(dst, 0)
+	dstLen := fgen.cur.NewExtractValue(dst, 1)
+	srcData := fgen.cur.NewExtractValue(src, 0)
+	srcLen := fgen.cur.NewExtractValue(src, 1)
+	// n = min(len(dst), len(src))
+	less := fgen.cur.NewICmp(enum.IPredSLT, dstLen, srcLen)
+	n := fgen.cur.NewSelect(less, dstLen, srcLen)
+	elemType := dstData.Type().(*types.PointerType).ElemType
+	size := fgen.cur.NewMul(n, sizeof(elemType))
+	i8Ptr := types.NewPointer(types.I8)
+	memmove := fgen.gen.intrinsic("llvm.memmove.p0i8.p0i8.i64", types.Void, i8Ptr, i8Ptr, types.I64, types.I1)
+	dstPtr := fgen.cur.NewBitCast(dstData, i8Ptr)
+	srcPtr := fgen.cur.NewBitCast(srcData, i8Ptr)
+	fgen.cur.NewCall(memmove, dstPtr, srcPtr, size, constant.False)
+	return n, nil
+}
+
+// lowerLen lowers the Go call expression of the builtin function len to LLVM IR,
+// emitting to f. Calls with constant results (e.g. `len("abc")` and `len(a)` for
+// arrays a) are folded by the type-checker; the remaining calls are lowered
+// here.
+func (fgen *funcGen) lowerLen(goCallExpr *ast.CallExpr) (value.Value, error) {
+	// func len(v Type) int
+	goArg := goCallExpr.Args[0]
+	x, err := fgen.lowerExprUse(goArg)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	goType := fgen.gen.subst(fgen.gen.pkg.TypesInfo.TypeOf(goArg))
+	if goPtrType, ok := goType.Underlying().(*gotypes.Pointer); ok {
+		// Pointer to array.
+		goType = goPtrType.Elem()
+	}
+	switch goType := goType.Underlying().(type) {
+	case *gotypes.Array:
+		// The array operand is evaluated for its side effects (e.g. function
+		// calls in `len(f())`), but its length is part of the type.
+		return constant.NewInt(types.I64, goType.Len()), nil
+	case *gotypes.Map:
+		// The runtime reports the number of entries, and 0 for nil maps.
+		f := fgen.gen.runtimeFunc("maplen", types.I64, fgen.gen.mapType())
+		return fgen.cur.NewCall(f, x), nil
+	case *gotypes.Chan:
+		// The runtime reports the number of queued elements, and 0 for nil
+		// channels.
+		f := fgen.gen.runtimeFunc("chanlen", types.I64, fgen.gen.chanType())
+		return fgen.cur.NewCall(f, x), nil
+	case *gotypes.Slice:
+		// Slices hold the length in the second field.
+		return fgen.cur.NewExtractValue(x, 1), nil
+	default:
+		if !isString(goType) {
+			return nil, errors.Errorf("invalid argument type of builtin function len; expected string, array, pointer to array, slice, map or channel type, got %v", goType)
+		}
+		// Strings hold the length in the second field.
+		return fgen.cur.NewExtractValue(x, 1), nil
+	}
+}
+
+// lowerMake lowers the Go call expression of the builtin function make to LLVM
+// IR, emitting to f.
+func (fgen *funcGen) lowerMake(goCallExpr *ast.CallExpr) (value.Value, error) {
+	// func make(t Type, size ...IntegerType) Type
+	goType := fgen.gen.subst(fgen.gen.pkg.TypesInfo.TypeOf(goCallExpr.Args[0]))
+	switch goType.Underlying().(type) {
+	case *gotypes.Slice:
+		return fgen.lowerMakeSlice(goCallExpr, goType)
+	case *gotypes.Map:
+		// The size hint is optional.
+		hint, err := fgen.lowerSliceIndex(argAt(goCallExpr, 1), constant.NewInt(types.I64, 0))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return fgen.newMap(goType, hint)
+	default:
+		panic(fmt.Errorf("support for builtin function make of type %v not yet implemented", goType))
+	}
+}
+
+// lowerMakeSlice lowers the Go call expression of the builtin function make of
+// the given slice type to LLVM IR, emitting to f.
+//
+// A zeroed backing array of cap elements is allocated on the heap; the capacity
+// defaults to the length. Bounds checks ensure that 0 <= len <= cap.
+func (fgen *funcGen) lowerMakeSlice(goCallExpr *ast.CallExpr, goType gotypes.Type) (value.Value, error) {
+	// make([]T, len)
+	// make([]T, len, cap)
+	goSliceType := goType.Underlying().(*gotypes.Slice)
+	t, err := fgen.gen.irType(goType)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	elemType, err := fgen.gen.irType(goSliceType.Elem())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	length, err := fgen.lowerSliceIndex(goCallExpr.Args[1], nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	capacity, err := fgen.lowerSliceIndex(argAt(goCallExpr, 2), length)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	zero := constant.NewInt(types.I64, 0)
+	fgen.checkSlice(zero, length, capacity, capacity)
+	i8Ptr := types.NewPointer(types.I8)
+	alloc := fgen.gen.runtimeFunc("alloc", i8Ptr, types.I64)
+	size := fgen.cur.NewMul(capacity, sizeof(elemType))
+	mem := fgen.cur.NewCall(alloc, size)
+	memset := fgen.gen.intrinsic("llvm.memset.p0i8.i64", types.Void, i8Ptr, types.I8, types.I64, types.I1)
+	fgen.cur.NewCall(memset, mem, constant.NewInt(types.I8, 0), size, constant.False)
+	data := fgen.cur.NewBitCast(mem, types.NewPointer(elemType))
+	return irgen.NewAggregate(fgen.cur, t, data, length, capacity), nil
+}
+
+// lowerPanic lowers the Go call expression of the builtin function panic to
+// LLVM IR, emitting to f.
+func (fgen *funcGen) lowerPanic(goCallExpr *ast.CallExpr) (value.Value, error) {
+	// panic(v interface{})
+	goEface := gotypes.NewInterfaceType(nil, nil)
+	arg, err := fgen.lowerArg(goCallExpr.Args[0], goEface)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	f := fgen.gen.runtimeFunc("panic", types.Void, arg.Type())
+	result := fgen.cur.NewCall(f, arg)
+	// The runtime panic helper does not return; continue lowering in an
+	// unreachable basic block.
+	fgen.cur.NewUnreachable()
+	fgen.cur = fgen.f.NewBlock("")
+	return result, nil
+}
+
+// lowerPrint lowers the Go call expression of the builtin function print or
+// println to LLVM IR, emitting to f. Each argument is printed by the runtime
+// helper of its type (e.g. @toy.printint for integers); println separates
+// arguments by spaces and ends with a newline.
+func (fgen *funcGen) lowerPrint(goCallExpr *ast.CallExpr, newline bool) error {
+	// func print(args ...Type)
+	// func println(args ...Type)
+	for i, goArg := range goCallExpr.Args {
+		if newline && i > 0 {
+			fgen.cur.NewCall(fgen.gen.runtimeFunc("printsp", types.Void))
+		}
+		if err := fgen.lowerPrintArg(goArg); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if newline {
+		fgen.cur.NewCall(fgen.gen.runtimeFunc("printnl", types.Void))
+	}
+	return nil
+}
+
+// lowerPrintArg lowers the printing of the given Go argument of the builtin
+// function print or println to LLVM IR, emitting to f.
+func (fgen *funcGen) lowerPrintArg(goArg ast.Expr) error {
+	x, err := fgen.lowerExprUse(goArg)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// Untyped constant arguments are printed with their default type.
+	goType := gotypes.Default(fgen.gen.subst(fgen.gen.pkg.TypesInfo.TypeOf(goArg)))
+	i8Ptr := types.NewPointer(types.I8)
+	var name string
+	var arg value.Value
+	switch goType := goType.Underlying().(type) {
+	case *gotypes.Basic:
+		info := goType.Info()
+		switch {
+		case info&gotypes.IsBoolean != 0:
+			name, arg = "printbool", x
+		case info&gotypes.IsUnsigned != 0:
+			name, arg = "printuint", fgen.extendInt(x, false)
+		case info&gotypes.IsInteger != 0:
+			name, arg = "printint", fgen.extendInt(x, true)
+		case info&gotypes.IsFloat != 0:
+			name, arg = "printfloat", x
+			if !types.Equal(x.Type(), types.Double) {
+				arg = fgen.cur.NewFPExt(x, types.Double)
+			}
+		case info&gotypes.IsString != 0:
+			name, arg = "printstring", x
+		case goType.Kind() == gotypes.UnsafePointer:
+			// unsafe.Pointer is represented as an integer of the word size.
+			name, arg = "printpointer", fgen.cur.NewIntToPtr(x, i8Ptr)
+		default:
+			panic(fmt.Errorf("support for printing argument of type %v not yet implemented", goType))
+		}
+	case *gotypes.Pointer, *gotypes.Chan, *gotypes.Map, *gotypes.Signature:
+		// Pointer-shaped values are printed as addresses.
+		name, arg = "printpointer", fgen.cur.NewBitCast(x, i8Ptr)
+	default:
+		panic(fmt.Errorf("support for printing argument of type %v not yet implemented", goType))
+	}
+	f := fgen.gen.runtimeFunc(name, types.Void, arg.Type())
+	fgen.cur.NewCall(f, arg)
+	return nil
+}
+
+// extendInt extends the given integer value to 64 bits, emitting to f. The
+// signed parameter specifies whether to sign or zero extend the value.
+func (fgen *funcGen) extendInt(x value.Value, signed bool) value.Value {
+	t := x.Type().(*types.IntType)
+	switch {
+	case t.BitSize == 64:
+		return x
+	case signed:
+		return fgen.cur.NewSExt(x, types.I64)
+	default:
+		return fgen.cur.NewZExt(x, types.I64)
+	}
+}
+
+// ### [ Helper functions ] ####################################################
+
+// argAt returns the argument at the given index of the Go call expression, or
+// nil if not present (e.g. optional capacity of `make([]T, n)`).
+func argAt(goCallExpr *ast.CallExpr, i int) ast.Expr {
+	if i < len(goCallExpr.Args) {
+		return goCallExpr.Args[i]
+	}
+	return nil
+}
+
+// builtinOf returns the Go builtin function referred to by the given callee
+// expression. The boolean return value indicates success.
+//
+// Builtin functions are identified by their type-checker objects rather than by
+// name, as local identifiers may shadow builtins (e.g. `len := 5`).
+func (gen *Generator) builtinOf(goCallee ast.Expr) (*gotypes.Builtin, bool) {
+	var goIdent *ast.Ident
+	switch goCallee := unparen(goCallee).(type) {
+	case *ast.Ident:
+		goIdent = goCallee
+	case *ast.SelectorExpr:
+		// Qualified builtin (e.g. unsafe.Sizeof).
+		goIdent = goCallee.Sel
+	default:
+		return nil, false
+	}
+	builtin, ok := gen.pkg.TypesInfo.Uses[goIdent].(*gotypes.Builtin)
+	return builtin, ok
+}
+
+// unparen returns the Go expression with any enclosing parentheses removed.
+func unparen(goExpr ast.Expr) ast.Expr {
+	for {
+		goParenExpr, ok := goExpr.(*ast.ParenExpr)
+		if !ok {
+			return goExpr
+		}
+		goExpr = goParenExpr.X
+	}
+}
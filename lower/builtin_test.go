@@ -0,0 +1,133 @@
+package lower
+
+import "testing"
+
+func TestCopyMemmove(t *testing.T) {
+	const src = `package p
+
+func shift(a []int) {
+	copy(a[1:], a)
+}
+`
+	def := mustFuncDef(t, lowerSource(t, src), "shift")
+	// The source and destination may overlap.
+	wantIR(t, def, "call void @llvm.memmove.p0i8.p0i8.i64(")
+	rejectIR(t, def, "memcpy")
+}
+
+func TestPrintBoolPointer(t *testing.T) {
+	const src = `package p
+
+import "unsafe"
+
+func f(p *int) {
+	print(true, p)
+}
+
+func g(p *int) {
+	print(unsafe.Pointer(p))
+}
+`
+	module := lowerSource(t, src)
+	def := mustFuncDef(t, module, "f")
+	wantIR(t, def, "call void @toy.printbool(i1 true)", "call void @toy.printpointer(i8* ")
+	wantIR(t, funcDecl(module, "toy.printbool"), "declare void @toy.printbool(i1")
+	wantIR(t, funcDecl(module, "toy.printpointer"), "declare void @toy.printpointer(i8*")
+	// print does not separate arguments by spaces.
+	rejectIR(t, def, "@toy.printsp", "@toy.printnl")
+	// unsafe.Pointer is represented as an integer of the word size.
+	g := mustFuncDef(t, module, "g")
+	wantIR(t, g, "inttoptr i64 ", "call void @toy.printpointer(i8* ")
+	rejectIR(t, g, "bitcast i64")
+}
+
+func TestCapMake(t *testing.T) {
+	const src = `package p
+
+func f() int {
+	return cap(make([]int, 2, 8))
+}
+
+func g(a [4]int) int {
+	return cap(a)
+}
+`
+	module := lowerSource(t, src)
+	// Slices hold the capacity in the third field.
+	def := mustFuncDef(t, module, "f")
+	wantIR(t, def, "insertvalue { i64*, i64, i64 } ", "i64 8, 2\n", "extractvalue { i64*, i64, i64 } ")
+	// The capacity of arrays is folded by the type-checker.
+	wantIR(t, mustFuncDef(t, module, "g"), "ret i64 4")
+}
+
+func TestAppendElem(t *testing.T) {
+	const src = `package p
+
+func f(s []int, x int) []int {
+	return append(s, x)
+}
+`
+	def := mustFuncDef(t, lowerSource(t, src), "f")
+	// The backing array is grown if the new length exceeds the capacity.
+	wantIR(t, def, "add i64 ", ", 1\n", "icmp ugt i64 ", "call i8* @toy.alloc(i64 ", "call void @llvm.memcpy.p0i8.p0i8.i64(")
+	wantCount(t, def, "phi i64", 2)
+	// The element is stored past the length of the slice.
+	wantIR(t, def, "store i64 ")
+	rejectIR(t, def, "memmove")
+}
+
+func TestMakeSliceZero(t *testing.T) {
+	const src = `package p
+
+func f(n int) int {
+	s := make([]int, n)
+	return s[n-1]
+}
+`
+	def := mustFuncDef(t, lowerSource(t, src), "f")
+	// The backing array of cap elements is allocated on the heap and zeroed;
+	// the capacity defaults to the length.
+	wantIR(t, def, "call i8* @toy.alloc(i64 ", "call void @llvm.memset.p0i8.i64(i8* ", ", i8 0, i64 ")
+	wantIR(t, def, "call void @toy.panicindex(", "getelementptr i64, i64* ")
+}
+
+func TestCopyCount(t *testing.T) {
+	const src = `package p
+
+func f() int {
+	dst := make([]int, 5)
+	src := []int{1, 2}
+	return copy(dst, src)
+}
+
+func g(dst []byte, s string) int {
+	return copy(dst, s)
+}
+`
+	module := lowerSource(t, src)
+	// The number of copied elements is the minimum of the source and
+	// destination lengths; i.e. the length of the shorter source.
+	def := mustFuncDef(t, module, "f")
+	wantIR(t, def, "icmp slt i64 ", "select i1 ", "call void @llvm.memmove.p0i8.p0i8.i64(", "ret i64 %")
+	// Strings may be copied to byte slices.
+	wantIR(t, mustFuncDef(t, module, "g"), "extractvalue { i8*, i64 } ", "call void @llvm.memmove.p0i8.p0i8.i64(")
+}
+
+func TestComplexConj(t *testing.T) {
+	const src = `package p
+
+func conj(z complex128) complex128 {
+	return complex(real(z), -imag(z))
+}
+
+func c() complex128 {
+	return complex(1, -2)
+}
+`
+	module := lowerSource(t, src)
+	def := mustFuncDef(t, module, "conj")
+	wantCount(t, def, "extractvalue { double, double } ", 2)
+	wantIR(t, def, "fsub double -0.0, ", "insertvalue { double, double } ")
+	// Constant complex values are folded by the type-checker.
+	wantIR(t, mustFuncDef(t, module, "c"), "ret { double, double } { double 1.0, double -2.0 }")
+}
@@ -0,0 +1,86 @@
+package lower
+
+import (
+	"go/ast"
+	gotypes "go/types"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/pkg/errors"
+)
+
+// lowerClose lowers the Go call expression of the builtin function close to
+// LLVM IR, emitting to f. Once closed and drained, receives from the channel
+// report false (e.g. terminating range statements over the channel).
+func (fgen *funcGen) lowerClose(goCallExpr *ast.CallExpr) (value.Value, error) {
+	ch, err := fgen.lowerExprUse(goCallExpr.Args[0])
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	chanclose := fgen.gen.runtimeFunc("chanclose", types.Void, fgen.gen.chanType())
+	return fgen.cur.NewCall(chanclose, ch), nil
+}
+
+// lowerChanRange lowers the Go range-statement over a channel to LLVM IR,
+// emitting to f. Values are received from the channel until it is closed.
+//
+//	loop:
+//	   ok := toy.chanrecv(ch, &elem)
+//	   if !ok { goto done }
+//	   v = elem
+//	   body
+//	   goto loop
+//	done:
+func (fgen *funcGen) lowerChanRange(goRangeStmt *ast.RangeStmt, goChanType *gotypes.Chan) {
+	ch, err := fgen.lowerExprUse(goRangeStmt.X)
+	if err != nil {
+		fgen.gen.eh(err)
+		return
+	}
+	elemType, err := fgen.gen.irType(goChanType.Elem())
+	if err != nil {
+		fgen.gen.eh(err)
+		return
+	}
+	// Iteration variable; a range over a channel permits at most one iteration
+	// variable.
+	v, err := fgen.lowerRangeVar(goRangeStmt.Key, goRangeStmt.Tok)
+	if err != nil {
+		fgen.gen.eh(err)
+		return
+	}
+	elemMem := fgen.newLocal(elemType)
+	i8Ptr := types.NewPointer(types.I8)
+	chanrecv := fgen.gen.runtimeFunc("chanrecv", types.I1, fgen.gen.chanType(), i8Ptr)
+	//loopBlock := ir.NewBlock("loop")
+	loopBlock := ir.NewBlock("")
+	//bodyBlock := ir.NewBlock("body")
+	bodyBlock := ir.NewBlock("")
+	//doneBlock := ir.NewBlock("done")
+	doneBlock := ir.NewBlock("")
+	// Receive.
+	fgen.cur.NewBr(loopBlock)
+	fgen.cur = loopBlock
+	fgen.f.Blocks = append(fgen.f.Blocks, loopBlock)
+	dst := fgen.cur.NewBitCast(elemMem, i8Ptr)
+	// The receive reports false when the channel is closed and drained.
+	ok := fgen.cur.NewCall(chanrecv, ch, dst)
+	fgen.cur.NewCondBr(ok, bodyBlock, doneBlock)
+	// Body.
+	fgen.cur = bodyBlock
+	fgen.f.Blocks = append(fgen.f.Blocks, bodyBlock)
+	if v != nil {
+		elem := fgen.newLoad(elemMem)
+		fgen.newStore(elem, v)
+	}
+	pop := fgen.pushTarget(goRangeStmt, doneBlock, loopBlock)
+	fgen.lowerStmt(goRangeStmt.Body)
+	pop()
+	if fgen.cur.Term == nil {
+		fgen.cur.NewBr(loopBlock)
+	}
+	// Done.
+	fgen.cur = doneBlock
+	fgen.f.Blocks = append(fgen.f.Blocks, doneBlock)
+}
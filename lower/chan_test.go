@@ -0,0 +1,55 @@
+package lower
+
+import "testing"
+
+func TestChanRange(t *testing.T) {
+	const src = `package p
+
+func sum(ch chan int) int {
+	s := 0
+	for v := range ch {
+		s += v
+	}
+	return s
+}
+`
+	def := mustFuncDef(t, lowerSource(t, src), "sum")
+	// Values are received until the channel is closed and drained.
+	wantIR(t, def, "call i1 @toy.chanrecv(%toy.chan* ")
+	wantCount(t, def, "@toy.chanrecv", 1)
+	wantCount(t, def, "br i1 ", 1)
+}
+
+func TestChanClose(t *testing.T) {
+	const src = `package p
+
+func f(ch chan<- int) {
+	close(ch)
+}
+`
+	module := lowerSource(t, src)
+	def := mustFuncDef(t, module, "f")
+	wantIR(t, def, "call void @toy.chanclose(%toy.chan* ")
+	wantIR(t, funcDecl(module, "toy.chanclose"), "declare void @toy.chanclose(%toy.chan*")
+}
+
+func TestSelectSendRecv(t *testing.T) {
+	const src = `package p
+
+func f(in, out chan int, x int) int {
+	select {
+	case out <- x:
+		return 1
+	case v := <-in:
+		return v
+	}
+}
+`
+	def := mustFuncDef(t, lowerSource(t, src), "f")
+	// Both cases are passed to the runtime, which blocks without a default
+	// clause; the send case is marked as such.
+	wantIR(t, def, "alloca [2 x { %toy.chan*, i8*, i1 }]", "call { i64, i1 } @toy.selectgo({ %toy.chan*, i8*, i1 }* ", "i64 2, i1 true)")
+	wantIR(t, def, "i1 true, 2\n", "i1 false, 2\n")
+	// The selected case is run by switching on the index of the case.
+	wantIR(t, def, "switch i64 ", "i64 0, label ", "i64 1, label ")
+}
@@ -0,0 +1,259 @@
+package lower
+
+import (
+	"fmt"
+	"go/ast"
+	gotypes "go/types"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/pkg/errors"
+)
+
+// lowerFuncLit lowers the Go function literal to LLVM IR, emitting to f. The
+// body of the function literal is lowered to a top-level function with a name
+// derived from the enclosing function (e.g. "main.func1" for the first function
+// literal of "main"), and the returned value is a pointer to a closure object
+// of the function.
+//
+// Variables captured by the function literal are captured by reference; the
+// closure object holds pointers to the (heap allocated) captured variables,
+// and is allocated on the heap at the site of the function literal.
+//
+//	closure := &{main.func1, &x, &y}
+func (fgen *funcGen) lowerFuncLit(goFuncLit *ast.FuncLit) (value.Value, error) {
+	vars := fgen.gen.freeVars(goFuncLit)
+	fgen.funcLits++
+	funcName := fmt.Sprintf("%s.func%d", fgen.f.Name(), fgen.funcLits)
+	ctx := ir.NewParam("ctx", types.NewPointer(types.I8))
+	params := append([]*ir.Param{ctx}, fgen.gen.irParams(goFuncLit.Type.Params)...)
+	retType := fgen.gen.irRetType(goFuncLit.Type.Results)
+	f := fgen.gen.m.NewFunc(funcName, retType, params...)
+	// Function literals are only referred to by value from within the package.
+	f.Linkage = enum.LinkageInternal
+	if prev, ok := fgen.gen.funcs[funcName]; ok {
+		return nil, errors.Errorf("function %q already present; prev `%v`, new `%v`", funcName, prev, f)
+	}
+	fgen.gen.funcs[funcName] = f
+	// Lower body of function literal.
+	litGen := fgen.gen.newFuncGen()
+	litGen.f = f
+	litGen.scope = fgen.gen.pkg.TypesInfo.Scopes[goFuncLit.Type]
+	litGen.sig = fgen.gen.pkg.TypesInfo.TypeOf(goFuncLit).(*gotypes.Signature)
+	litGen.ctx = ctx
+	litGen.captures = vars
+	litGen.lowerBody(nil, goFuncLit.Type, goFuncLit.Body)
+	if len(vars) == 0 {
+		// Function literals without captured variables share a statically
+		// allocated closure object.
+		return fgen.gen.funcVal(funcName, f), nil
+	}
+	closureType, err := fgen.gen.closureType(f, vars)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	closure := fgen.newObject(closureType)
+	zero := constant.NewInt(types.I32, 0)
+	fgen.newStore(f, fgen.cur.NewGetElementPtr(closure, zero, zero))
+	for i, v := range vars {
+		mem, ok := fgen.locals[v]
+		if !ok {
+			return nil, errors.Errorf("unable to locate captured variable %q of function literal in %q", v.Name(), fgen.f.Name())
+		}
+		field := fgen.cur.NewGetElementPtr(closure, zero, constant.NewInt(types.I32, int64(i+1)))
+		fgen.newStore(mem, field)
+	}
+	t, err := fgen.gen.irTypeOf(goFuncLit)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return fgen.cur.NewBitCast(closure, t), nil
+}
+
+// closureType returns the LLVM IR type of closure objects of the given
+// function with the given captured variables; i.e. the function pointer
+// followed by pointers to the captured variables.
+func (gen *Generator) closureType(f *ir.Function, vars []*gotypes.Var) (*types.StructType, error) {
+	fields := []types.Type{f.Type()}
+	for _, v := range vars {
+		t, err := gen.irType(v.Type())
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		fields = append(fields, types.NewPointer(t))
+	}
+	return types.NewStruct(fields...), nil
+}
+
+// lowerCaptures locates the variables captured by the function literal being
+// lowered through the closure object passed as hidden first parameter, emitting
+// to f.
+func (fgen *funcGen) lowerCaptures() {
+	if fgen.ctx == nil || len(fgen.captures) == 0 {
+		return
+	}
+	closureType, err := fgen.gen.closureType(fgen.f, fgen.captures)
+	if err != nil {
+		fgen.gen.eh(err)
+		return
+	}
+	closure := fgen.cur.NewBitCast(fgen.ctx, types.NewPointer(closureType))
+	zero := constant.NewInt(types.I32, 0)
+	for i, v := range fgen.captures {
+		field := fgen.cur.NewGetElementPtr(closure, zero, constant.NewInt(types.I32, int64(i+1)))
+		fgen.locals[v] = fgen.newLoad(field)
+	}
+}
+
+// indexCaptured records the local variables captured by function literals
+// within the given Go function body, so that they may be allocated on the heap.
+func (fgen *funcGen) indexCaptured(goBody *ast.BlockStmt) {
+	ast.Inspect(goBody, func(n ast.Node) bool {
+		goFuncLit, ok := n.(*ast.FuncLit)
+		if !ok {
+			return true
+		}
+		for _, v := range fgen.gen.freeVars(goFuncLit) {
+			fgen.captured[v] = true
+		}
+		return true
+	})
+}
+
+// funcValue returns the function value of the given top-level function; i.e. a
+// pointer to a statically allocated closure object of a wrapper function which
+// ignores the closure object and invokes the top-level function.
+func (gen *Generator) funcValue(f *ir.Function) constant.Constant {
+	wrapperName := f.Name() + "$closure"
+	wrapper, ok := gen.funcs[wrapperName]
+	if !ok {
+		params := []*ir.Param{ir.NewParam("ctx", types.NewPointer(types.I8))}
+		for _, param := range f.Params {
+			params = append(params, ir.NewParam(param.Name(), param.Type()))
+		}
+		wrapper = gen.m.NewFunc(wrapperName, f.Sig.RetType, params...)
+		wrapper.Linkage = enum.LinkageInternal
+		gen.funcs[wrapperName] = wrapper
+		entry := wrapper.NewBlock("entry")
+		var args []value.Value
+		for _, param := range params[1:] {
+			args = append(args, param)
+		}
+		result := entry.NewCall(f, args...)
+		if types.Equal(f.Sig.RetType, types.Void) {
+			entry.NewRet(nil)
+		} else {
+			entry.NewRet(result)
+		}
+	}
+	return gen.funcVal(f.Name(), wrapper)
+}
+
+// funcVal returns a pointer to the statically allocated closure object of the
+// given closure function without captured variables, adding the closure object
+// to the module on first use.
+func (gen *Generator) funcVal(name string, f *ir.Function) constant.Constant {
+	globalName := name + "$funcval"
+	g, ok := gen.globals[globalName]
+	if !ok {
+		g = gen.m.NewGlobalDef(globalName, constant.NewStruct(f))
+		g.Immutable = true
+		g.Linkage = enum.LinkageInternal
+		gen.globals[globalName] = g
+	}
+	return g
+}
+
+// lowerClosureCall lowers the Go call expression of a function value (e.g.
+// `f(x)` for `f` of function type) to LLVM IR, emitting to f. The function is
+// invoked through the closure object, with a pointer to the closure object as
+// hidden first argument.
+//
+//	closure.f(closure, args...)
+func (fgen *funcGen) lowerClosureCall(goCallExpr *ast.CallExpr) (value.Value, error) {
+	closure, err := fgen.lowerExprUse(goCallExpr.Fun)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	args, err := fgen.lowerCallArgs(goCallExpr)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	fgen.checkNil(closure)
+	zero := constant.NewInt(types.I32, 0)
+	fn := fgen.newLoad(fgen.cur.NewGetElementPtr(closure, zero, zero))
+	ctx := fgen.cur.NewBitCast(closure, types.NewPointer(types.I8))
+	return fgen.cur.NewCall(fn, append([]value.Value{ctx}, args...)...), nil
+}
+
+// directCallee returns the top-level function or generic function instance
+// referred to by the given Go callee expression (e.g. `f` in `f(x)` or
+// `Max[int]` in `Max[int](x, y)`), which is called directly rather than through
+// a closure object. The boolean return value indicates success.
+func (fgen *funcGen) directCallee(goCallee ast.Expr) (*ir.Function, bool) {
+	var goIdent *ast.Ident
+	switch goCallee := unparen(goCallee).(type) {
+	case *ast.Ident:
+		goIdent = goCallee
+	case *ast.IndexExpr:
+		goIdent, _ = goCallee.X.(*ast.Ident)
+	case *ast.IndexListExpr:
+		goIdent, _ = goCallee.X.(*ast.Ident)
+	}
+	if goIdent == nil {
+		return nil, false
+	}
+	if _, ok := fgen.gen.pkg.TypesInfo.Uses[goIdent].(*gotypes.Func); !ok {
+		return nil, false
+	}
+	v, err := fgen.lowerIdentExpr(goIdent)
+	if err != nil {
+		return nil, false
+	}
+	f, ok := v.(*ir.Function)
+	return f, ok
+}
+
+// freeVars returns the free variables of the given Go function literal, in
+// order of first use. Free variables are the local variables of enclosing
+// functions referred to by the function literal, and are captured by the
+// environment of the closure.
+//
+// References to package-level variables (of this or imported packages) are not
+// captured, as they are resolved directly through their global variable
+// definitions or declarations.
+func (gen *Generator) freeVars(goFuncLit *ast.FuncLit) []*gotypes.Var {
+	var vars []*gotypes.Var
+	seen := make(map[*gotypes.Var]bool)
+	ast.Inspect(goFuncLit.Body, func(n ast.Node) bool {
+		goIdent, ok := n.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		v, ok := gen.pkg.TypesInfo.Uses[goIdent].(*gotypes.Var)
+		if !ok || v.IsField() || seen[v] {
+			return true
+		}
+		if isPackageLevel(v) {
+			// Package-level variable; not captured.
+			return true
+		}
+		if goFuncLit.Pos() <= v.Pos() && v.Pos() < goFuncLit.End() {
+			// Parameter or local variable of the function literal.
+			return true
+		}
+		seen[v] = true
+		vars = append(vars, v)
+		return true
+	})
+	return vars
+}
+
+// isPackageLevel reports whether the given Go variable is declared at package
+// scope.
+func isPackageLevel(v *gotypes.Var) bool {
+	return v.Pkg() != nil && v.Parent() == v.Pkg().Scope()
+}
@@ -0,0 +1,181 @@
+package lower
+
+import (
+	"fmt"
+	"go/ast"
+	gotypes "go/types"
+
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/pkg/errors"
+)
+
+// lowerConvExpr lowers the Go conversion expression (e.g. `int(x)`) to LLVM IR,
+// emitting to f.
+func (fgen *funcGen) lowerConvExpr(goCallExpr *ast.CallExpr) (value.Value, error) {
+	if len(goCallExpr.Args) != 1 {
+		return nil, errors.Errorf("invalid number of arguments to conversion; expected 1, got %d", len(goCallExpr.Args))
+	}
+	goArg := goCallExpr.Args[0]
+	x, err := fgen.lowerExprUse(goArg)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	from := fgen.gen.pkg.TypesInfo.TypeOf(goArg)
+	to := fgen.gen.pkg.TypesInfo.TypeOf(goCallExpr)
+	return fgen.convert(x, from, to)
+}
+
+// convert converts the LLVM IR value x from the Go type from to the Go type to,
+// emitting to f.
+func (fgen *funcGen) convert(x value.Value, from, to gotypes.Type) (value.Value, error) {
+	// Substitute type parameters of generic function instance.
+	from, to = fgen.gen.subst(from), fgen.gen.subst(to)
+	t, err := fgen.gen.irType(to)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	switch {
+	case isChan(from) && isChan(to):
+		// Conversion between channel directions (e.g. chan int to chan<- int);
+		// identical underlying representation.
+		return x, nil
+	case gotypes.IsInterface(from) && gotypes.IsInterface(to) && !gotypes.Identical(from.Underlying(), to.Underlying()):
+		// Conversion between interfaces of different method sets.
+		return fgen.convertIface(x, from, to, t)
+	case isBasic(from) && gotypes.Identical(from.Underlying(), to.Underlying()):
+		// Conversion between types of identical underlying basic type (e.g.
+		// `Color(n)` for `type Color int`); the type definition of the named
+		// type shares the representation of its underlying type.
+		return x, nil
+	case types.Equal(x.Type(), t):
+		// Identical underlying representation; nothing to do.
+		return x, nil
+	case isUntypedNil(from):
+		// Zero value of pointer, slice, map, channel, function or interface.
+		return constant.NewZeroInitializer(t), nil
+	case gotypes.IsInterface(to) && !gotypes.IsInterface(from):
+		// Box concrete value.
+		return fgen.box(x, from, to.Underlying().(*gotypes.Interface), t)
+	case isInteger(from) && isInteger(to):
+		fromSize, _ := bitSize(x.Type())
+		toSize, _ := bitSize(t)
+		switch {
+		case fromSize > toSize:
+			return fgen.cur.NewTrunc(x, t), nil
+		case fromSize < toSize:
+			// The widening is determined by the signedness of the source type;
+			// sign extend signed integers and zero extend unsigned integers.
+			if isUnsigned(from) {
+				return fgen.cur.NewZExt(x, t), nil
+			}
+			return fgen.cur.NewSExt(x, t), nil
+		default:
+			// Same size (e.g. int to uint); nothing to do.
+			return x, nil
+		}
+	case isFloat(from) && isInteger(to):
+		// Go truncates floating-point values towards zero when converting to
+		// integers, as do fptosi and fptoui. If the truncated value cannot be
+		// represented by the integer type (e.g. NaN or out of range), the result
+		// is implementation-defined in Go; the LLVM IR result is a poison value.
+		if isUnsigned(to) {
+			return fgen.cur.NewFPToUI(x, t), nil
+		}
+		return fgen.cur.NewFPToSI(x, t), nil
+	case isPointer(from) && isUnsafePointer(to):
+		// unsafe.Pointer is represented as an integer of the word size.
+		return fgen.cur.NewPtrToInt(x, t), nil
+	case isUnsafePointer(from) && isPointer(to):
+		return fgen.cur.NewIntToPtr(x, t), nil
+	case isString(to) && isRuneSlice(from):
+		// Encode each rune of the slice as UTF-8.
+		f := fgen.gen.runtimeFunc("runeslicetostr", t, x.Type())
+		return fgen.cur.NewCall(f, x), nil
+	case isRuneSlice(to) && isString(from):
+		// Decode each UTF-8 encoded code point of the string into a newly
+		// allocated rune slice.
+		f := fgen.gen.runtimeFunc("strtorunes", t, x.Type())
+		return fgen.cur.NewCall(f, x), nil
+	default:
+		panic(fmt.Errorf("support for conversion from Go type %v to %v not yet implemented", from, to))
+	}
+}
+
+// ### [ Helper functions ] ####################################################
+
+// isBasic reports whether the underlying type of the given Go type is a basic
+// type.
+func isBasic(goType gotypes.Type) bool {
+	_, ok := goType.Underlying().(*gotypes.Basic)
+	return ok
+}
+
+// isInteger reports whether the given Go type is an integer type.
+func isInteger(goType gotypes.Type) bool {
+	return hasBasicInfo(goType, gotypes.IsInteger)
+}
+
+// isUnsigned reports whether the given Go type is an unsigned integer type.
+func isUnsigned(goType gotypes.Type) bool {
+	return hasBasicInfo(goType, gotypes.IsUnsigned)
+}
+
+// isFloat reports whether the given Go type is a floating-point type.
+func isFloat(goType gotypes.Type) bool {
+	return hasBasicInfo(goType, gotypes.IsFloat)
+}
+
+// isComplex reports whether the given Go type is a complex type.
+func isComplex(goType gotypes.Type) bool {
+	return hasBasicInfo(goType, gotypes.IsComplex)
+}
+
+// isString reports whether the given Go type is a string type.
+func isString(goType gotypes.Type) bool {
+	return hasBasicInfo(goType, gotypes.IsString)
+}
+
+// isChan reports whether the given Go type is a channel type.
+func isChan(goType gotypes.Type) bool {
+	_, ok := goType.Underlying().(*gotypes.Chan)
+	return ok
+}
+
+// isPointer reports whether the given Go type is a pointer type.
+func isPointer(goType gotypes.Type) bool {
+	_, ok := goType.Underlying().(*gotypes.Pointer)
+	return ok
+}
+
+// isUnsafePointer reports whether the given Go type is unsafe.Pointer.
+func isUnsafePointer(goType gotypes.Type) bool {
+	t, ok := goType.Underlying().(*gotypes.Basic)
+	return ok && t.Kind() == gotypes.UnsafePointer
+}
+
+// isUntypedNil reports whether the given Go type is the type of the untyped
+// nil value.
+func isUntypedNil(goType gotypes.Type) bool {
+	t, ok := goType.(*gotypes.Basic)
+	return ok && t.Kind() == gotypes.UntypedNil
+}
+
+// isRuneSlice reports whether the given Go type is a slice type with rune
+// elements.
+func isRuneSlice(goType gotypes.Type) bool {
+	t, ok := goType.Underlying().(*gotypes.Slice)
+	if !ok {
+		return false
+	}
+	elem, ok := t.Elem().Underlying().(*gotypes.Basic)
+	return ok && elem.Kind() == gotypes.Int32
+}
+
+// hasBasicInfo reports whether the underlying type of the given Go type is a
+// basic type with the specified properties.
+func hasBasicInfo(goType gotypes.Type, info gotypes.BasicInfo) bool {
+	t, ok := goType.Underlying().(*gotypes.Basic)
+	return ok && t.Info()&info != 0
+}
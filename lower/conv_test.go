@@ -0,0 +1,116 @@
+package lower
+
+import "testing"
+
+func TestFloatToIntTrunc(t *testing.T) {
+	const src = `package p
+
+func pos() int {
+	x := 3.9
+	return int(x)
+}
+
+func neg() int {
+	x := -3.9
+	return int(x)
+}
+
+func unsigned(x float64) uint8 {
+	return uint8(x)
+}
+`
+	module := lowerSource(t, src)
+	// fptosi truncates towards zero; i.e. 3 and -3 respectively.
+	wantIR(t, mustFuncDef(t, module, "pos"), "double 3.9", "fptosi double")
+	wantIR(t, mustFuncDef(t, module, "neg"), "double -3.9", "fptosi double")
+	wantIR(t, mustFuncDef(t, module, "unsigned"), "fptoui double")
+}
+
+func TestRuneSliceToString(t *testing.T) {
+	const src = `package p
+
+func f() string {
+	return string([]rune{0x4e2d, 0x6587})
+}
+`
+	def := mustFuncDef(t, lowerSource(t, src), "f")
+	wantIR(t, def, "@toy.runeslicetostr(")
+}
+
+func TestStringToRuneSlice(t *testing.T) {
+	const src = `package p
+
+func f() []rune {
+	return []rune("héllo")
+}
+`
+	def := mustFuncDef(t, lowerSource(t, src), "f")
+	wantIR(t, def, "@toy.strtorunes(")
+}
+
+func TestIntWidening(t *testing.T) {
+	const src = `package p
+
+func signed(x int8) int64 {
+	return int64(x)
+}
+
+func unsigned(x uint8) int64 {
+	return int64(x)
+}
+`
+	module := lowerSource(t, src)
+	// int64(int8(-1)) == -1
+	signed := mustFuncDef(t, module, "signed")
+	wantIR(t, signed, "sext i8")
+	rejectIR(t, signed, "zext")
+	// int64(uint8(0xFF)) == 255
+	unsigned := mustFuncDef(t, module, "unsigned")
+	wantIR(t, unsigned, "zext i8")
+	rejectIR(t, unsigned, "sext")
+}
+
+func TestChanDirConv(t *testing.T) {
+	const src = `package p
+
+func f(c chan int) chan<- int {
+	return (chan<- int)(c)
+}
+`
+	def := mustFuncDef(t, lowerSource(t, src), "f")
+	// Identical representation; no conversion instruction.
+	wantIR(t, def, "ret %toy.chan* %")
+	rejectIR(t, def, "bitcast")
+}
+
+func TestReturnConv(t *testing.T) {
+	const src = `package p
+
+func toI32(x int) int32 {
+	return int32(x)
+}
+`
+	def := mustFuncDef(t, lowerSource(t, src), "toI32")
+	wantCount(t, def, "trunc i64", 1)
+	wantCount(t, def, "ret i32", 1)
+}
+
+func TestNamedIntConv(t *testing.T) {
+	const src = `package p
+
+type Color int
+
+func c() Color {
+	return Color(2)
+}
+
+func f(x int) Color {
+	return Color(x)
+}
+`
+	module := lowerSource(t, src)
+	wantIR(t, mustFuncDef(t, module, "c"), "ret i64 2")
+	// Conversion between integer types of the same width is the identity.
+	def := mustFuncDef(t, module, "f")
+	rejectIR(t, def, "sext", "zext", "trunc", "bitcast")
+}
@@ -0,0 +1,89 @@
+package lower
+
+import (
+	"go/ast"
+	gotypes "go/types"
+
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// lowerAggregateEqual lowers the equality comparison of the Go struct or array
+// operands x and y of the binary expression to LLVM IR, emitting to f.
+//
+// The inequality comparison `x != y` is lowered as the negation of `x == y`.
+func (fgen *funcGen) lowerAggregateEqual(goExpr *ast.BinaryExpr, x, y value.Value) value.Value {
+	goType := fgen.gen.subst(fgen.gen.pkg.TypesInfo.TypeOf(goExpr.X))
+	return fgen.lowerValueEqual(x, y, goType)
+}
+
+// lowerValueEqual lowers the equality comparison of the values x and y of the
+// given comparable Go type to LLVM IR, emitting to f.
+//
+// Struct values are equal if their corresponding non-blank fields are equal,
+// and array values are equal if their corresponding elements are equal.
+func (fgen *funcGen) lowerValueEqual(x, y value.Value, goType gotypes.Type) value.Value {
+	switch goType := goType.Underlying().(type) {
+	case *gotypes.Struct:
+		var eq value.Value = constant.True
+		for i := 0; i < goType.NumFields(); i++ {
+			field := goType.Field(i)
+			if field.Name() == "_" {
+				// Blank fields are ignored.
+				continue
+			}
+			xField := fgen.cur.NewExtractValue(x, uint64(i))
+			yField := fgen.cur.NewExtractValue(y, uint64(i))
+			eq = fgen.andEqual(eq, fgen.lowerValueEqual(xField, yField, field.Type()))
+		}
+		return eq
+	case *gotypes.Array:
+		var eq value.Value = constant.True
+		for i := int64(0); i < goType.Len(); i++ {
+			xElem := fgen.cur.NewExtractValue(x, uint64(i))
+			yElem := fgen.cur.NewExtractValue(y, uint64(i))
+			eq = fgen.andEqual(eq, fgen.lowerValueEqual(xElem, yElem, goType.Elem()))
+		}
+		return eq
+	case *gotypes.Interface:
+		// Interface values are equal if they have identical dynamic types and
+		// equal dynamic values.
+		f := fgen.gen.runtimeFunc("ifaceeq", types.I1, x.Type(), y.Type())
+		return fgen.cur.NewCall(f, x, y)
+	case *gotypes.Basic:
+		switch {
+		case goType.Info()&gotypes.IsString != 0:
+			// String values are equal if they have equal lengths and bytes.
+			f := fgen.gen.runtimeFunc("streq", types.I1, x.Type(), y.Type())
+			return fgen.cur.NewCall(f, x, y)
+		case goType.Info()&gotypes.IsFloat != 0:
+			// Ordered comparison, as NaN compares unequal to every value.
+			return fgen.cur.NewFCmp(enum.FPredOEQ, x, y)
+		}
+	}
+	return fgen.cur.NewICmp(enum.IPredEQ, x, y)
+}
+
+// andEqual returns the conjunction of the accumulated equality eq and the
+// equality of the next field or element, emitting to f.
+func (fgen *funcGen) andEqual(eq, next value.Value) value.Value {
+	if eq == constant.True {
+		// First field or element.
+		return next
+	}
+	return fgen.cur.NewAnd(eq, next)
+}
+
+// isAggregateOperand reports whether the operands of the given Go binary
+// expression are of struct or array type.
+func (fgen *funcGen) isAggregateOperand(goExpr *ast.BinaryExpr) bool {
+	goType := fgen.gen.subst(fgen.gen.pkg.TypesInfo.TypeOf(goExpr.X))
+	switch goType.Underlying().(type) {
+	case *gotypes.Struct, *gotypes.Array:
+		return true
+	default:
+		return false
+	}
+}
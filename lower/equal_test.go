@@ -0,0 +1,33 @@
+package lower
+
+import "testing"
+
+func TestStructNotEqual(t *testing.T) {
+	const src = `package p
+
+type Point struct {
+	X, Y int
+}
+
+func eq(a, b Point) bool {
+	return a == b
+}
+
+func ne(a, b Point) bool {
+	return a != b
+}
+`
+	module := lowerSource(t, src)
+	// Structs are equal if their corresponding fields are equal.
+	eq := mustFuncDef(t, module, "eq")
+	wantCount(t, eq, "icmp eq i64", 2)
+	wantCount(t, eq, "and i1", 1)
+	rejectIR(t, eq, "xor")
+	// `a != b` is the negation of `a == b`.
+	ne := mustFuncDef(t, module, "ne")
+	wantCount(t, ne, "icmp eq i64", 2)
+	wantCount(t, ne, "and i1", 1)
+	wantIR(t, ne, ", true\n")
+	wantCount(t, ne, "xor i1", 1)
+	rejectIR(t, ne, "icmp ne")
+}
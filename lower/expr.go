@@ -3,7 +3,11 @@ package lower
 import (
 	"fmt"
 	"go/ast"
+	goconstant "go/constant"
 	"go/token"
+	gotypes "go/types"
+	"math"
+	"math/big"
 	"strconv"
 	"strings"
 
@@ -12,6 +16,7 @@ import (
 	"github.com/llir/llvm/ir/enum"
 	"github.com/llir/llvm/ir/types"
 	"github.com/llir/llvm/ir/value"
+	"github.com/mewspring/toy/irgen"
 	"github.com/pkg/errors"
 )
 
@@ -19,6 +24,21 @@ import (
 
 // lowerExpr lowers the Go expression to LLVM IR, emitting to f.
 func (fgen *funcGen) lowerExpr(goExpr ast.Expr) (value.Value, error) {
+	// Constant expression (e.g. named constants, iota and constant arithmetic)
+	// folded by the type-checker. This includes the length of constant strings
+	// and comparisons of constants, thus `len("abc") > 0` is folded to true.
+	if tv, ok := fgen.gen.pkg.TypesInfo.Types[goExpr]; ok && tv.Value != nil {
+		return fgen.gen.lowerConst(tv.Type, tv.Value)
+	}
+	// Predeclared nil, of the type required by its context (e.g. interface type
+	// in `r != nil`).
+	if fgen.isNil(goExpr) {
+		typ, err := fgen.gen.irTypeOf(goExpr)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return zeroValue(typ), nil
+	}
 	switch goExpr := goExpr.(type) {
 	case *ast.BasicLit:
 		return fgen.gen.lowerBasicLit(goExpr), nil
@@ -26,8 +46,45 @@ func (fgen *funcGen) lowerExpr(goExpr ast.Expr) (value.Value, error) {
 		return fgen.lowerBinaryExpr(goExpr)
 	case *ast.CallExpr:
 		return fgen.lowerCallExpr(goExpr)
+	case *ast.CompositeLit:
+		return fgen.lowerCompositeLit(goExpr)
+	case *ast.FuncLit:
+		return fgen.lowerFuncLit(goExpr)
 	case *ast.Ident:
-		return fgen.lowerIdentExpr(goExpr)
+		return fgen.lowerIdentValue(goExpr)
+	case *ast.IndexExpr:
+		if goIdent, ok := goExpr.X.(*ast.Ident); ok && fgen.isInstance(goIdent) {
+			// Explicit instantiation of generic function (e.g. `Max[int]`).
+			return fgen.lowerIdentValue(goIdent)
+		}
+		goType := fgen.gen.subst(fgen.gen.pkg.TypesInfo.TypeOf(goExpr.X))
+		if goMapType, ok := goType.Underlying().(*gotypes.Map); ok {
+			return fgen.lowerMapIndex(goExpr, goMapType)
+		}
+		if fgen.isAddressable(goExpr) {
+			return fgen.lowerIndexAddr(goExpr)
+		}
+		return fgen.lowerIndexValue(goExpr)
+	case *ast.IndexListExpr:
+		if goIdent, ok := goExpr.X.(*ast.Ident); ok && fgen.isInstance(goIdent) {
+			// Explicit instantiation of generic function (e.g. `Map[int, string]`).
+			return fgen.lowerIdentValue(goIdent)
+		}
+		panic(fmt.Errorf("support for index list expression `%v` not yet implemented", goExpr.X))
+	case *ast.ParenExpr:
+		return fgen.lowerExpr(goExpr.X)
+	case *ast.SelectorExpr:
+		if fgen.isAddressable(goExpr) {
+			return fgen.lowerFieldAddr(goExpr)
+		}
+		if sel, ok := fgen.gen.pkg.TypesInfo.Selections[goExpr]; ok && sel.Kind() == gotypes.FieldVal {
+			return fgen.lowerFieldValue(goExpr)
+		}
+		panic(fmt.Errorf("support for selector expression `%v` not yet implemented", goExpr.Sel))
+	case *ast.SliceExpr:
+		return fgen.lowerSliceExpr(goExpr)
+	case *ast.StarExpr:
+		return fgen.lowerStarExpr(goExpr)
 	case *ast.UnaryExpr:
 		return fgen.lowerUnaryExpr(goExpr)
 	default:
@@ -37,6 +94,10 @@ func (fgen *funcGen) lowerExpr(goExpr ast.Expr) (value.Value, error) {
 
 // lowerBinaryExpr lowers the Go binary expression to LLVM IR, emitting to f.
 func (fgen *funcGen) lowerBinaryExpr(goExpr *ast.BinaryExpr) (value.Value, error) {
+	// Logical operations.
+	if goExpr.Op == token.LAND || goExpr.Op == token.LOR {
+		return fgen.lowerLogicalExpr(goExpr)
+	}
 	x, err := fgen.lowerExprUse(goExpr.X)
 	if err != nil {
 		return nil, errors.WithStack(err)
@@ -45,8 +106,215 @@ func (fgen *funcGen) lowerBinaryExpr(goExpr *ast.BinaryExpr) (value.Value, error
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+	x, y = fgen.matchWidth(goExpr.Op, x, y)
 	t := x.Type()
 	switch goExpr.Op {
+	case token.LSS, token.LEQ, token.GTR, token.GEQ:
+		if types.IsPointer(t) {
+			goType := fgen.gen.subst(fgen.gen.pkg.TypesInfo.TypeOf(goExpr.X))
+			return nil, errors.Errorf("invalid operand type to '%s' binary expression; pointers may only be compared for equality, got %v", goExpr.Op, goType)
+		}
+	}
+	switch goExpr.Op {
+	// Arithmetic and bitwise operations.
+	case token.ADD, token.SUB, token.MUL, token.QUO, token.REM, token.SHL, token.SHR, token.AND, token.OR, token.XOR, token.AND_NOT:
+		return fgen.lowerBinaryOp(goExpr.Op, x, y)
+	// Relational operations.
+	//
+	// Floating-point comparisons follow IEEE 754 semantics, as required by Go;
+	// NaN compares unequal to every value, including itself. Thus ordered
+	// predicates are used for all comparisons except !=, which uses an unordered
+	// predicate to report true when either operand is NaN.
+	case token.EQL: // ==
+		if fgen.isIfaceOperand(goExpr) {
+			return fgen.lowerIfaceEqual(goExpr, x, y)
+		}
+		if fgen.isAggregateOperand(goExpr) {
+			return fgen.lowerAggregateEqual(goExpr, x, y), nil
+		}
+		if isFloatOrFloatVectorType(t) {
+			return fgen.cur.NewFCmp(enum.FPredOEQ, x, y), nil
+		}
+		return fgen.cur.NewICmp(enum.IPredEQ, x, y), nil
+	case token.NEQ: // !=
+		if fgen.isIfaceOperand(goExpr) {
+			eq, err := fgen.lowerIfaceEqual(goExpr, x, y)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			return fgen.cur.NewXor(eq, constant.True), nil
+		}
+		if fgen.isAggregateOperand(goExpr) {
+			eq := fgen.lowerAggregateEqual(goExpr, x, y)
+			return fgen.cur.NewXor(eq, constant.True), nil
+		}
+		if isFloatOrFloatVectorType(t) {
+			return fgen.cur.NewFCmp(enum.FPredUNE, x, y), nil
+		}
+		return fgen.cur.NewICmp(enum.IPredNE, x, y), nil
+	case token.LSS: // <
+		if isFloatOrFloatVectorType(t) {
+			return fgen.cur.NewFCmp(enum.FPredOLT, x, y), nil
+		}
+		if fgen.isUnsignedValue(x) {
+			return fgen.cur.NewICmp(enum.IPredULT, x, y), nil
+		}
+		return fgen.cur.NewICmp(enum.IPredSLT, x, y), nil
+	case token.LEQ: // <=
+		if isFloatOrFloatVectorType(t) {
+			return fgen.cur.NewFCmp(enum.FPredOLE, x, y), nil
+		}
+		if fgen.isUnsignedValue(x) {
+			return fgen.cur.NewICmp(enum.IPredULE, x, y), nil
+		}
+		return fgen.cur.NewICmp(enum.IPredSLE, x, y), nil
+	case token.GTR: // >
+		if isFloatOrFloatVectorType(t) {
+			return fgen.cur.NewFCmp(enum.FPredOGT, x, y), nil
+		}
+		if fgen.isUnsignedValue(x) {
+			return fgen.cur.NewICmp(enum.IPredUGT, x, y), nil
+		}
+		return fgen.cur.NewICmp(enum.IPredSGT, x, y), nil
+	case token.GEQ: // >=
+		if isFloatOrFloatVectorType(t) {
+			return fgen.cur.NewFCmp(enum.FPredOGE, x, y), nil
+		}
+		if fgen.isUnsignedValue(x) {
+			return fgen.cur.NewICmp(enum.IPredUGE, x, y), nil
+		}
+		return fgen.cur.NewICmp(enum.IPredSGE, x, y), nil
+	default:
+		panic(fmt.Errorf("support for '%s' binary expression not yet implemented", goExpr.Op))
+	}
+}
+
+// lowerStringConcat lowers the concatenation of the Go string operands x and y
+// (e.g. `x + y` or `x += y`) to LLVM IR, emitting to f.
+//
+// Concatenation is provided by the runtime helper @toy.strconcat, which
+// allocates a new buffer of combined length on the heap, copies the bytes of x
+// followed by the bytes of y, and returns the resulting string. Strings are
+// passed and returned by value using the string struct type:
+//
+//	declare {i8*, i64} @toy.strconcat({i8*, i64} %x, {i8*, i64} %y)
+func (fgen *funcGen) lowerStringConcat(x, y value.Value) value.Value {
+	f := fgen.gen.runtimeFunc("strconcat", x.Type(), x.Type(), y.Type())
+	return fgen.cur.NewCall(f, x, y)
+}
+
+// lowerLogicalExpr lowers the Go logical binary expression (`x && y` or
+// `x || y`) to LLVM IR, emitting to f. The right operand is only evaluated if
+// the left operand does not determine the result (short-circuit evaluation).
+//
+//	x && y: br x, rhs, follow   ; result is false if x is false
+//	x || y: br x, follow, rhs   ; result is true if x is true
+//	rhs:    br follow
+//	follow: phi [short-circuit result, x block], [y, rhs block]
+func (fgen *funcGen) lowerLogicalExpr(goExpr *ast.BinaryExpr) (value.Value, error) {
+	x, err := fgen.lowerExprUse(goExpr.X)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if !types.Equal(x.Type(), types.I1) {
+		return nil, errors.Errorf("invalid operand type to '%s' binary expression; expected boolean type, got %T", goExpr.Op, x.Type())
+	}
+	xBlock := fgen.cur
+	rhsBlock := fgen.f.NewBlock("")
+	followBlock := ir.NewBlock("")
+	var short constant.Constant
+	if goExpr.Op == token.LAND {
+		short = constant.False
+		xBlock.NewCondBr(x, rhsBlock, followBlock)
+	} else {
+		short = constant.True
+		xBlock.NewCondBr(x, followBlock, rhsBlock)
+	}
+	fgen.cur = rhsBlock
+	y, err := fgen.lowerExprUse(goExpr.Y)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if !types.Equal(y.Type(), types.I1) {
+		return nil, errors.Errorf("invalid operand type to '%s' binary expression; expected boolean type, got %T", goExpr.Op, y.Type())
+	}
+	// The right operand may span several basic blocks (e.g. nested logical
+	// expressions).
+	yBlock := fgen.cur
+	yBlock.NewBr(followBlock)
+	fgen.f.Blocks = append(fgen.f.Blocks, followBlock)
+	fgen.cur = followBlock
+	return fgen.cur.NewPhi(ir.NewIncoming(short, xBlock), ir.NewIncoming(y, yBlock)), nil
+}
+
+// lowerBinaryOp lowers the arithmetic or bitwise binary operation with the
+// given operator and operands to LLVM IR, emitting to f. The operation is shared
+// by binary expressions (e.g. `x + y`) and compound assignments (e.g. `x += y`).
+func (fgen *funcGen) lowerBinaryOp(op token.Token, x, y value.Value) (value.Value, error) {
+	x, y = fgen.matchWidth(op, x, y)
+	v, err := fgen.lowerBinaryOpValue(op, x, y)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// The result of arithmetic and bitwise operations has the Go type of the
+	// left operand; thus intermediate unsigned results retain unsigned
+	// semantics (e.g. in `(x + y) / z`).
+	if goType, ok := fgen.goTypes[x]; ok {
+		fgen.goTypes[v] = goType
+	}
+	return v, nil
+}
+
+// matchWidth reconciles the integer widths of the operands x and y of the
+// binary operation op, emitting to f.
+//
+// Typed operands of arithmetic and bitwise operations have identical types in
+// valid Go code, but constant operands are materialized at the width of the
+// other operand (e.g. untyped constant in `x + 1` for `x` of type int16).
+// Shift counts may be of any integer type (e.g. `x << n` for `x` of type int16
+// and `n` of type uint), and are thus extended or truncated to the width of the
+// shifted operand.
+func (fgen *funcGen) matchWidth(op token.Token, x, y value.Value) (value.Value, value.Value) {
+	xType, ok := x.Type().(*types.IntType)
+	if !ok {
+		return x, y
+	}
+	yType, ok := y.Type().(*types.IntType)
+	if !ok || xType.BitSize == yType.BitSize {
+		return x, y
+	}
+	if c, ok := y.(*constant.Int); ok {
+		return x, fgen.retype(c, xType)
+	}
+	if op == token.SHL || op == token.SHR {
+		// Shift counts are non-negative.
+		if yType.BitSize < xType.BitSize {
+			return x, fgen.cur.NewZExt(y, xType)
+		}
+		return x, fgen.cur.NewTrunc(y, xType)
+	}
+	if c, ok := x.(*constant.Int); ok {
+		return fgen.retype(c, yType), y
+	}
+	return x, y
+}
+
+// retype returns the integer constant c materialized at the given width,
+// retaining the Go type of c.
+func (fgen *funcGen) retype(c *constant.Int, t *types.IntType) *constant.Int {
+	v := newBigInt(t, c.X)
+	if goType, ok := fgen.goTypes[c]; ok {
+		fgen.goTypes[v] = goType
+	}
+	return v
+}
+
+// lowerBinaryOpValue lowers the Go arithmetic or bitwise binary operation op
+// on x and y to LLVM IR, emitting to f.
+func (fgen *funcGen) lowerBinaryOpValue(op token.Token, x, y value.Value) (value.Value, error) {
+	unsigned := fgen.isUnsignedValue(x)
+	t := x.Type()
+	switch op {
 	// Binary operations.
 	case token.ADD: // +
 		switch {
@@ -54,8 +322,10 @@ func (fgen *funcGen) lowerBinaryExpr(goExpr *ast.BinaryExpr) (value.Value, error
 			return fgen.cur.NewAdd(x, y), nil
 		case isFloatOrFloatVectorType(t):
 			return fgen.cur.NewFAdd(x, y), nil
+		case fgen.isStringValue(x):
+			return fgen.lowerStringConcat(x, y), nil
 		default:
-			return nil, errors.Errorf("invalid operand type to '%s' binary expression; expected integer scalar, integer vector, floating-point scalar or floating-point vector type, got %T", goExpr.Op, t)
+			return nil, errors.Errorf("invalid operand type to '%s' binary expression; expected integer scalar, integer vector, floating-point scalar, floating-point vector or string type, got %T", op, t)
 		}
 	case token.SUB: // -
 		switch {
@@ -64,7 +334,7 @@ func (fgen *funcGen) lowerBinaryExpr(goExpr *ast.BinaryExpr) (value.Value, error
 		case isFloatOrFloatVectorType(t):
 			return fgen.cur.NewFSub(x, y), nil
 		default:
-			return nil, errors.Errorf("invalid operand type to '%s' binary expression; expected integer scalar, integer vector, floating-point scalar or floating-point vector type, got %T", goExpr.Op, t)
+			return nil, errors.Errorf("invalid operand type to '%s' binary expression; expected integer scalar, integer vector, floating-point scalar or floating-point vector type, got %T", op, t)
 		}
 	case token.MUL: // *
 		switch {
@@ -73,59 +343,66 @@ func (fgen *funcGen) lowerBinaryExpr(goExpr *ast.BinaryExpr) (value.Value, error
 		case isFloatOrFloatVectorType(t):
 			return fgen.cur.NewFMul(x, y), nil
 		default:
-			return nil, errors.Errorf("invalid operand type to '%s' binary expression; expected integer scalar, integer vector, floating-point scalar or floating-point vector type, got %T", goExpr.Op, t)
+			return nil, errors.Errorf("invalid operand type to '%s' binary expression; expected integer scalar, integer vector, floating-point scalar or floating-point vector type, got %T", op, t)
 		}
 	case token.QUO: // /
 		switch {
 		case isIntOrIntVectorType(t):
-			// TODO: figure out how to distinguish signed vs. unsigned values. Use
-			// SDiv for signed and UDiv for unsigned.
+			if unsigned {
+				return fgen.cur.NewUDiv(x, y), nil
+			}
 			return fgen.cur.NewSDiv(x, y), nil
 		case isFloatOrFloatVectorType(t):
 			return fgen.cur.NewFDiv(x, y), nil
 		default:
-			return nil, errors.Errorf("invalid operand type to '%s' binary expression; expected integer scalar, integer vector, floating-point scalar or floating-point vector type, got %T", goExpr.Op, t)
+			return nil, errors.Errorf("invalid operand type to '%s' binary expression; expected integer scalar, integer vector, floating-point scalar or floating-point vector type, got %T", op, t)
 		}
 	case token.REM: // %
 		switch {
 		case isIntOrIntVectorType(t):
-			// TODO: figure out how to distinguish signed vs. unsigned values. Use
-			// SRem for signed and URem for unsigned.
+			if unsigned {
+				return fgen.cur.NewURem(x, y), nil
+			}
 			return fgen.cur.NewSRem(x, y), nil
 		case isFloatOrFloatVectorType(t):
 			return fgen.cur.NewFRem(x, y), nil
 		default:
-			return nil, errors.Errorf("invalid operand type to '%s' binary expression; expected integer scalar, integer vector, floating-point scalar or floating-point vector type, got %T", goExpr.Op, t)
+			return nil, errors.Errorf("invalid operand type to '%s' binary expression; expected integer scalar, integer vector, floating-point scalar or floating-point vector type, got %T", op, t)
 		}
 	// Bitwise operations.
 	case token.SHL: // <<
 		if !isIntOrIntVectorType(t) {
-			return nil, errors.Errorf("invalid operand type to '%s' binary expression; expected integer scalar or integer vector type, got %T", goExpr.Op, t)
+			return nil, errors.Errorf("invalid operand type to '%s' binary expression; expected integer scalar or integer vector type, got %T", op, t)
 		}
 		return fgen.cur.NewShl(x, y), nil
 	case token.SHR: // >>
 		if !isIntOrIntVectorType(t) {
-			return nil, errors.Errorf("invalid operand type to '%s' binary expression; expected integer scalar or integer vector type, got %T", goExpr.Op, t)
+			return nil, errors.Errorf("invalid operand type to '%s' binary expression; expected integer scalar or integer vector type, got %T", op, t)
 		}
-		return fgen.cur.NewLShr(x, y), nil
+		// Go specifies arithmetic shifts for signed integers and logical shifts
+		// for unsigned integers.
+		if unsigned {
+			return fgen.cur.NewLShr(x, y), nil
+		}
+		return fgen.cur.NewAShr(x, y), nil
 	case token.AND: // &
 		if !isIntOrIntVectorType(t) {
-			return nil, errors.Errorf("invalid operand type to '%s' binary expression; expected integer scalar or integer vector type, got %T", goExpr.Op, t)
+			return nil, errors.Errorf("invalid operand type to '%s' binary expression; expected integer scalar or integer vector type, got %T", op, t)
 		}
 		return fgen.cur.NewAnd(x, y), nil
 	case token.OR: // |
 		if !isIntOrIntVectorType(t) {
-			return nil, errors.Errorf("invalid operand type to '%s' binary expression; expected integer scalar or integer vector type, got %T", goExpr.Op, t)
+			return nil, errors.Errorf("invalid operand type to '%s' binary expression; expected integer scalar or integer vector type, got %T", op, t)
 		}
 		return fgen.cur.NewOr(x, y), nil
 	case token.XOR: // ^
 		if !isIntOrIntVectorType(t) {
-			return nil, errors.Errorf("invalid operand type to '%s' binary expression; expected integer scalar or integer vector type, got %T", goExpr.Op, t)
+			return nil, errors.Errorf("invalid operand type to '%s' binary expression; expected integer scalar or integer vector type, got %T", op, t)
 		}
 		return fgen.cur.NewXor(x, y), nil
 	case token.AND_NOT: // &^
 		if !isIntOrIntVectorType(t) {
-			return nil, errors.Errorf("invalid operand type to '%s' binary expression; expected integer scalar or integer vector type, got %T", goExpr.Op, t)
+			return nil, errors.Errorf("invalid operand type to '%s' binary expression; expected integer scalar or integer vector type, got %T", op, t)
 		}
 		// Mask.
 		mask, err := allOnes(y.Type())
@@ -134,66 +411,199 @@ func (fgen *funcGen) lowerBinaryExpr(goExpr *ast.BinaryExpr) (value.Value, error
 		}
 		tmp := fgen.cur.NewXor(y, mask)
 		return fgen.cur.NewAnd(x, tmp), nil
-	// Logical operations.
-	case token.LAND: // &&
-		switch {
-		case !types.Equal(x.Type(), types.I1):
-			return nil, errors.Errorf("invalid operand type to '%s' binary expression; expected boolean type, got %T", goExpr.Op, x.Type())
-		case !types.Equal(y.Type(), types.I1):
-			return nil, errors.Errorf("invalid operand type to '%s' binary expression; expected boolean type, got %T", goExpr.Op, y.Type())
-		}
-		return fgen.cur.NewAnd(x, y), nil
-	case token.LOR: // ||
-		switch {
-		case !types.Equal(x.Type(), types.I1):
-			return nil, errors.Errorf("invalid operand type to '%s' binary expression; expected boolean type, got %T", goExpr.Op, x.Type())
-		case !types.Equal(y.Type(), types.I1):
-			return nil, errors.Errorf("invalid operand type to '%s' binary expression; expected boolean type, got %T", goExpr.Op, y.Type())
-		}
-		return fgen.cur.NewOr(x, y), nil
-	// Relational operations.
-	case token.EQL: // ==
-		return fgen.cur.NewICmp(enum.IPredEQ, x, y), nil
-	case token.NEQ: // !=
-		return fgen.cur.NewICmp(enum.IPredNE, x, y), nil
-	case token.LSS: // <
-		// TODO: figure out how to distinguish signed vs. unsigned values. Use
-		// IPredSLT for signed and IPredULT for unsigned.
-		return fgen.cur.NewICmp(enum.IPredSLT, x, y), nil
-	case token.LEQ: // <=
-		// TODO: figure out how to distinguish signed vs. unsigned values. Use
-		// IPredSLE for signed and IPredULE for unsigned.
-		return fgen.cur.NewICmp(enum.IPredSLE, x, y), nil
-	case token.GTR: // >
-		// TODO: figure out how to distinguish signed vs. unsigned values. Use
-		// IPredSGT for signed and IPredUGT for unsigned.
-		return fgen.cur.NewICmp(enum.IPredSGT, x, y), nil
-	case token.GEQ: // >=
-		// TODO: figure out how to distinguish signed vs. unsigned values. Use
-		// IPredSGE for signed and IPredUGE for unsigned.
-		return fgen.cur.NewICmp(enum.IPredSGE, x, y), nil
 	default:
-		panic(fmt.Errorf("support for '%s' binary expression not yet implemented", goExpr.Op))
+		panic(fmt.Errorf("support for '%s' binary operation not yet implemented", op))
 	}
 }
 
 // lowerCallExpr lowers the Go call expression to LLVM IR, emitting to f.
 func (fgen *funcGen) lowerCallExpr(goCallExpr *ast.CallExpr) (value.Value, error) {
-	callee, err := fgen.lowerExprUse(goCallExpr.Fun)
+	// Conversion.
+	if fgen.gen.pkg.TypesInfo.Types[goCallExpr.Fun].IsType() {
+		return fgen.lowerConvExpr(goCallExpr)
+	}
+	// Builtin function call.
+	if builtin, ok := fgen.gen.builtinOf(goCallExpr.Fun); ok {
+		return fgen.lowerBuiltinCallExpr(builtin, goCallExpr)
+	}
+	// Atomic operations of the sync/atomic package.
+	if fn, ok := fgen.gen.atomicFuncOf(goCallExpr.Fun); ok {
+		return fgen.lowerAtomicCallExpr(fn, goCallExpr)
+	}
+	// Method call.
+	if goSelExpr, ok := unparen(goCallExpr.Fun).(*ast.SelectorExpr); ok {
+		if sel, ok := fgen.gen.pkg.TypesInfo.Selections[goSelExpr]; ok && sel.Kind() == gotypes.MethodVal {
+			return fgen.lowerMethodCall(goCallExpr, goSelExpr, sel)
+		}
+	}
+	// Direct call of top-level function.
+	if callee, ok := fgen.directCallee(goCallExpr.Fun); ok {
+		args, err := fgen.lowerCallArgs(goCallExpr)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return fgen.cur.NewCall(callee, args...), nil
+	}
+	// Indirect call of function value.
+	return fgen.lowerClosureCall(goCallExpr)
+}
+
+// lowerCallArgs lowers the arguments of the Go call expression to LLVM IR,
+// emitting to f. Arguments are converted to the types of their corresponding
+// parameters (e.g. boxing of values passed to interface parameters), and the
+// trailing arguments of variadic function calls are packed into a slice.
+func (fgen *funcGen) lowerCallArgs(goCallExpr *ast.CallExpr) ([]value.Value, error) {
+	goCalleeType := fgen.gen.pkg.TypesInfo.TypeOf(goCallExpr.Fun)
+	sig, ok := goCalleeType.Underlying().(*gotypes.Signature)
+	if !ok {
+		return nil, errors.Errorf("invalid callee type; expected function signature, got %v", goCalleeType)
+	}
+	params := sig.Params()
+	nfixed := params.Len()
+	// Variadic arguments are passed as is when followed by `...` (e.g.
+	// `f(args...)`).
+	variadic := sig.Variadic() && !goCallExpr.Ellipsis.IsValid()
+	if variadic {
+		nfixed--
+	}
+	vs, goArgTypes, err := fgen.lowerCallArgValues(goCallExpr)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	args, err := fgen.lowerExprs(goCallExpr.Args)
+	if len(vs) < nfixed {
+		return nil, errors.Errorf("invalid number of arguments in call to function of type %v; expected at least %d, got %d", goCalleeType, nfixed, len(vs))
+	}
+	var args []value.Value
+	for i, v := range vs[:nfixed] {
+		arg, err := fgen.convert(v, goArgTypes[i], params.At(i).Type())
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		args = append(args, arg)
+	}
+	if variadic {
+		goSliceType := params.At(nfixed).Type()
+		arg, err := fgen.lowerVariadicArgs(vs[nfixed:], goArgTypes[nfixed:], goSliceType)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// lowerCallArgValues lowers the arguments of the Go call expression to LLVM IR,
+// emitting to f, and returns the argument values together with their Go types.
+//
+// A single multi-valued call passed as the arguments of another call (e.g.
+// `g(f())`) is expanded into one argument per result value.
+func (fgen *funcGen) lowerCallArgValues(goCallExpr *ast.CallExpr) ([]value.Value, []gotypes.Type, error) {
+	if len(goCallExpr.Args) == 1 {
+		goArg := goCallExpr.Args[0]
+		if goTuple, ok := fgen.gen.pkg.TypesInfo.TypeOf(goArg).(*gotypes.Tuple); ok {
+			results, err := fgen.lowerExpr(goArg)
+			if err != nil {
+				return nil, nil, errors.WithStack(err)
+			}
+			var vs []value.Value
+			var goTypes []gotypes.Type
+			for i := 0; i < goTuple.Len(); i++ {
+				vs = append(vs, fgen.cur.NewExtractValue(results, uint64(i)))
+				goTypes = append(goTypes, goTuple.At(i).Type())
+			}
+			return vs, goTypes, nil
+		}
+	}
+	var vs []value.Value
+	var goTypes []gotypes.Type
+	for _, goArg := range goCallExpr.Args {
+		v, err := fgen.lowerExprUse(goArg)
+		if err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+		vs = append(vs, v)
+		goTypes = append(goTypes, fgen.gen.pkg.TypesInfo.TypeOf(goArg))
+	}
+	return vs, goTypes, nil
+}
+
+// lowerArg lowers the Go argument to LLVM IR, emitting to f. The argument is
+// converted to the given Go parameter type.
+func (fgen *funcGen) lowerArg(goArg ast.Expr, goParamType gotypes.Type) (value.Value, error) {
+	arg, err := fgen.lowerExprUse(goArg)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	// TODO: handle goCallExpr.Ellipsis.
-	return fgen.cur.NewCall(callee, args...), nil
+	goArgType := fgen.gen.pkg.TypesInfo.TypeOf(goArg)
+	return fgen.convert(arg, goArgType, goParamType)
+}
+
+// lowerVariadicArgs lowers the trailing arguments of a variadic function call
+// to LLVM IR, emitting to f. The arguments, of the given Go types, are packed
+// into a new slice of the given Go slice type.
+func (fgen *funcGen) lowerVariadicArgs(vs []value.Value, goArgTypes []gotypes.Type, goSliceType gotypes.Type) (value.Value, error) {
+	t, err := fgen.gen.irType(goSliceType)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(vs) == 0 {
+		// nil slice.
+		return constant.NewZeroInitializer(t), nil
+	}
+	goElemType := goSliceType.Underlying().(*gotypes.Slice).Elem()
+	elemType, err := fgen.gen.irType(goElemType)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// Store arguments in backing array.
+	array := fgen.newObject(types.NewArray(uint64(len(vs)), elemType))
+	zero := constant.NewInt(types.I64, 0)
+	for i, v := range vs {
+		arg, err := fgen.convert(v, goArgTypes[i], goElemType)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		elem := fgen.cur.NewGetElementPtr(array, zero, constant.NewInt(types.I64, int64(i)))
+		fgen.newStore(arg, elem)
+	}
+	data := fgen.cur.NewGetElementPtr(array, zero, zero)
+	n := constant.NewInt(types.I64, int64(len(vs)))
+	return irgen.NewAggregate(fgen.cur, t, data, n, n), nil
+}
+
+// lowerCompositeLit lowers the Go composite literal to LLVM IR, emitting to f.
+func (fgen *funcGen) lowerCompositeLit(goLit *ast.CompositeLit) (value.Value, error) {
+	goType := fgen.gen.subst(fgen.gen.pkg.TypesInfo.TypeOf(goLit))
+	switch goType.Underlying().(type) {
+	case *gotypes.Map:
+		return fgen.lowerMapLit(goLit, goType)
+	case *gotypes.Struct:
+		return fgen.lowerStructLit(goLit, goType)
+	case *gotypes.Array:
+		return fgen.lowerArrayLit(goLit, goType)
+	case *gotypes.Slice:
+		return fgen.lowerSliceLit(goLit, goType)
+	default:
+		panic(fmt.Errorf("support for composite literal of type %v not yet implemented", goType))
+	}
 }
 
 // lowerIdentExpr lowers the Go identifier expression to LLVM IR, emitting to f.
 func (fgen *funcGen) lowerIdentExpr(goIdent *ast.Ident) (value.Value, error) {
 	name := goIdent.String()
+	// Identifiers are resolved through their type-checker objects, as local
+	// identifiers may shadow top-level and universe scope identifiers.
+	obj := fgen.gen.pkg.TypesInfo.ObjectOf(goIdent)
+	if v, ok := fgen.locals[obj]; ok {
+		return v, nil
+	}
+	if _, ok := obj.(*gotypes.Builtin); ok {
+		return nil, errors.Errorf("invalid use of builtin function %q; builtin functions must be called", name)
+	}
+	if inst, ok := fgen.gen.pkg.TypesInfo.Instances[goIdent]; ok {
+		// Instance of generic function (e.g. `Max[int]`).
+		return fgen.gen.instantiate(obj.(*gotypes.Func), inst.TypeArgs)
+	}
 	if f, ok := fgen.gen.funcs[name]; ok {
 		return f, nil
 	}
@@ -203,8 +613,178 @@ func (fgen *funcGen) lowerIdentExpr(goIdent *ast.Ident) (value.Value, error) {
 	return nil, errors.Errorf("unable to locate top-level definition of identifier %q", name)
 }
 
-// lowerBinaryExpr lowers the Go binary expression to LLVM IR, emitting to f.
+// lowerIdentValue lowers the Go identifier expression to LLVM IR, emitting to
+// f. Top-level functions used as values (e.g. `g := f`) are lowered to function
+// values.
+func (fgen *funcGen) lowerIdentValue(goIdent *ast.Ident) (value.Value, error) {
+	v, err := fgen.lowerIdentExpr(goIdent)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if f, ok := v.(*ir.Function); ok {
+		return fgen.gen.funcValue(f), nil
+	}
+	return v, nil
+}
+
+// lowerSliceExpr lowers the Go slice expression to LLVM IR, emitting to f.
+func (fgen *funcGen) lowerSliceExpr(goExpr *ast.SliceExpr) (value.Value, error) {
+	goXType := fgen.gen.pkg.TypesInfo.TypeOf(goExpr.X)
+	switch goXType := goXType.Underlying().(type) {
+	case *gotypes.Array:
+		// Slice of addressable array; x is the array memory.
+		array, err := fgen.lowerExprAddr(goExpr.X)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return fgen.lowerArraySlice(goExpr, array, goXType)
+	case *gotypes.Pointer:
+		goArrayType, ok := goXType.Elem().Underlying().(*gotypes.Array)
+		if !ok {
+			return nil, errors.Errorf("invalid operand type of slice expression; expected pointer to array, got %v", goXType)
+		}
+		// Slice of pointer to array; x is the array pointer.
+		array, err := fgen.lowerExprUse(goExpr.X)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return fgen.lowerArraySlice(goExpr, array, goArrayType)
+	case *gotypes.Slice:
+		s, err := fgen.lowerExprUse(goExpr.X)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return fgen.lowerSliceSlice(goExpr, s)
+	case *gotypes.Basic:
+		if goXType.Info()&gotypes.IsString == 0 {
+			return nil, errors.Errorf("invalid operand type of slice expression; expected string, got %v", goXType)
+		}
+		s, err := fgen.lowerExprUse(goExpr.X)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return fgen.lowerStringSlice(goExpr, s)
+	default:
+		panic(fmt.Errorf("support for slice expression of type %v not yet implemented", goXType))
+	}
+}
+
+// lowerSliceSlice lowers the Go slice expression of a slice to LLVM IR,
+// emitting to f. The s parameter holds the sliced slice value.
+func (fgen *funcGen) lowerSliceSlice(goExpr *ast.SliceExpr, s value.Value) (value.Value, error) {
+	// The low bound defaults to 0, the high bound defaults to the length of the
+	// slice, and the max bound defaults to the capacity of the slice.
+	data := fgen.cur.NewExtractValue(s, 0)
+	n := fgen.cur.NewExtractValue(s, 1)
+	c := fgen.cur.NewExtractValue(s, 2)
+	low, err := fgen.lowerSliceIndex(goExpr.Low, constant.NewInt(types.I64, 0))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	high, err := fgen.lowerSliceIndex(goExpr.High, n)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	max, err := fgen.lowerSliceIndex(goExpr.Max, c)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	fgen.checkSlice(low, high, max, c)
+	// data = &s[low]
+	newData := fgen.cur.NewGetElementPtr(data, low)
+	// len = high - low
+	length := fgen.cur.NewSub(high, low)
+	// cap = max - low
+	capacity := fgen.cur.NewSub(max, low)
+	return irgen.NewAggregate(fgen.cur, s.Type(), newData, length, capacity), nil
+}
+
+// lowerStringSlice lowers the Go slice expression of a string to LLVM IR,
+// emitting to f. The s parameter holds the sliced string value.
+func (fgen *funcGen) lowerStringSlice(goExpr *ast.SliceExpr, s value.Value) (value.Value, error) {
+	// The low bound defaults to 0, and the high bound defaults to the length of
+	// the string. Strings have no capacity, thus the three-index form is
+	// rejected by the type-checker.
+	data := fgen.cur.NewExtractValue(s, 0)
+	n := fgen.cur.NewExtractValue(s, 1)
+	low, err := fgen.lowerSliceIndex(goExpr.Low, constant.NewInt(types.I64, 0))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	high, err := fgen.lowerSliceIndex(goExpr.High, n)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	fgen.checkSlice(low, high, high, n)
+	// data = &s[low]
+	newData := fgen.cur.NewGetElementPtr(data, low)
+	// len = high - low
+	length := fgen.cur.NewSub(high, low)
+	return irgen.NewAggregate(fgen.cur, s.Type(), newData, length), nil
+}
+
+// lowerArraySlice lowers the Go slice expression of an array to LLVM IR,
+// emitting to f. The array parameter holds a pointer to the array memory.
+func (fgen *funcGen) lowerArraySlice(goExpr *ast.SliceExpr, array value.Value, goArrayType *gotypes.Array) (value.Value, error) {
+	// The low bound defaults to 0, and the high and max bounds default to the
+	// length of the array.
+	n := constant.NewInt(types.I64, goArrayType.Len())
+	low, err := fgen.lowerSliceIndex(goExpr.Low, constant.NewInt(types.I64, 0))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	high, err := fgen.lowerSliceIndex(goExpr.High, n)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	max, err := fgen.lowerSliceIndex(goExpr.Max, n)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	fgen.checkSlice(low, high, max, n)
+	typ, err := fgen.gen.irTypeOf(goExpr)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// data = &a[low]
+	zero := constant.NewInt(types.I64, 0)
+	data := fgen.cur.NewGetElementPtr(array, zero, low)
+	// len = high - low
+	length := fgen.cur.NewSub(high, low)
+	// cap = max - low
+	capacity := fgen.cur.NewSub(max, low)
+	return irgen.NewAggregate(fgen.cur, typ, data, length, capacity), nil
+}
+
+// lowerStarExpr lowers the Go pointer indirection expression (e.g. `*p`) to
+// LLVM IR, emitting to f. The returned value is the pointer to the memory of the
+// pointee, as pointer indirections are addressable.
+func (fgen *funcGen) lowerStarExpr(goExpr *ast.StarExpr) (value.Value, error) {
+	goType := fgen.gen.pkg.TypesInfo.TypeOf(goExpr.X)
+	if _, ok := goType.Underlying().(*gotypes.Pointer); !ok {
+		return nil, errors.Errorf("invalid operand type of pointer indirection; expected pointer, got %v", goType)
+	}
+	ptr, err := fgen.lowerExprUse(goExpr.X)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	fgen.checkNil(ptr)
+	return ptr, nil
+}
+
+// lowerUnaryExpr lowers the Go unary expression to LLVM IR, emitting to f.
 func (fgen *funcGen) lowerUnaryExpr(goExpr *ast.UnaryExpr) (value.Value, error) {
+	if goExpr.Op == token.AND { // &
+		// Address of addressable operand (e.g. `&x`, `&p.X` or `&a[i]`); the
+		// memory of local variables, global variables, fields and elements.
+		// Elements of slice literals are addressable, and point into the
+		// backing array allocated on the heap by the literal (e.g.
+		// `&[]int{1, 2, 3}[1]`).
+		if !fgen.isAddressable(goExpr.X) {
+			panic(fmt.Errorf("support for address of non-addressable operand %T not yet implemented", goExpr.X))
+		}
+		return fgen.lowerExprAddr(goExpr.X)
+	}
 	x, err := fgen.lowerExprUse(goExpr.X)
 	if err != nil {
 		return nil, errors.WithStack(err)
@@ -216,6 +796,15 @@ func (fgen *funcGen) lowerUnaryExpr(goExpr *ast.UnaryExpr) (value.Value, error)
 		// Plus prefix is optional and has no effect.
 		return x, nil
 	case token.SUB: // -
+		if isComplex(fgen.gen.pkg.TypesInfo.TypeOf(goExpr.X)) {
+			// Negate the real and imaginary parts.
+			re := fgen.negFloat(fgen.cur.NewExtractValue(x, 0))
+			im := fgen.negFloat(fgen.cur.NewExtractValue(x, 1))
+			return irgen.NewAggregate(fgen.cur, t, re, im), nil
+		}
+		if isFloatOrFloatVectorType(t) {
+			return fgen.negFloat(x), nil
+		}
 		zero, err := allZeros(t)
 		if err != nil {
 			return nil, errors.WithStack(err)
@@ -232,19 +821,53 @@ func (fgen *funcGen) lowerUnaryExpr(goExpr *ast.UnaryExpr) (value.Value, error)
 			return nil, errors.WithStack(err)
 		}
 		return fgen.cur.NewXor(x, mask), nil
-	//case token.MUL: // *
-	//case token.AND: // &
 	//case token.ARROW: // <-
 	default:
 		panic(fmt.Errorf("support for '%s' unary expression not yet implemented", goExpr.Op))
 	}
 }
 
+// negFloat negates the given floating-point scalar or floating-point vector
+// value, emitting to f.
+//
+// Negation is lowered to a subtraction from negative zero, which only flips
+// the sign bit; thus `-x` is -0.0 for x = 0.0, as required by IEEE 754.
+func (fgen *funcGen) negFloat(x value.Value) value.Value {
+	// -0.0 - x
+	var negZero constant.Constant
+	switch t := x.Type().(type) {
+	case *types.FloatType:
+		negZero = constant.NewFloat(t, math.Copysign(0, -1))
+	case *types.VectorType:
+		elem := constant.NewFloat(t.ElemType.(*types.FloatType), math.Copysign(0, -1))
+		elems := make([]constant.Constant, t.Len)
+		for i := range elems {
+			elems[i] = elem
+		}
+		negZero = constant.NewVector(elems...)
+	}
+	return fgen.cur.NewFSub(negZero, x)
+}
+
 // --- [ Lower expression with module generator ] ------------------------------
 
 // lowerGlobalInitExpr lowers the given Go global definition initialization
-// expression to LLVM IR, emitting to m.
-func (gen *Generator) lowerGlobalInitExpr(goExpr ast.Expr) (constant.Constant, error) {
+// expression of a global variable of the given Go type to LLVM IR, emitting to
+// m.
+func (gen *Generator) lowerGlobalInitExpr(goExpr ast.Expr, goType gotypes.Type) (constant.Constant, error) {
+	// Constant expression (e.g. `1 << N` where N is a package-level constant)
+	// folded by the type-checker.
+	if tv, ok := gen.pkg.TypesInfo.Types[goExpr]; ok && tv.Value != nil {
+		// Constants are represented by the declared type of the global variable
+		// (e.g. `double 1.0` for `var y float64 = 1`).
+		if !isBasic(goType) {
+			// Constants assigned to global variables of interface type (e.g.
+			// `var e interface{} = 1`) must be boxed, which requires an init
+			// function.
+			return nil, errors.Errorf("support for constant initializer of global variable of non-basic type %v not yet implemented", goType)
+		}
+		return gen.lowerConst(goType, tv.Value)
+	}
 	switch goExpr := goExpr.(type) {
 	// Constant.
 	case *ast.BasicLit:
@@ -269,11 +892,17 @@ func (gen *Generator) lowerBasicLit(goLit *ast.BasicLit) constant.Constant {
 		if !ok {
 			panic(fmt.Errorf("invalid type of integer literal; expected *types.IntType, got %T", t))
 		}
-		x, err := constant.NewIntFromString(t, goLit.Value)
-		if err != nil {
-			panic(fmt.Errorf("unable to parse integer literal %q; %v", goLit.Value, err))
+		// Base prefix is determined by the literal (e.g. 0x, 0o, 0b), and
+		// underscores are permitted as digit separators.
+		x, ok := new(big.Int).SetString(goLit.Value, 0)
+		if !ok {
+			panic(fmt.Errorf("unable to parse integer literal %q", goLit.Value))
 		}
-		return x
+		goType := gen.pkg.TypesInfo.TypeOf(goLit)
+		if !fitsInt(x, t.BitSize, isUnsigned(goType)) {
+			gen.Errorf("integer literal %s overflows type %v", goLit.Value, goType)
+		}
+		return newBigInt(t, x)
 	case token.FLOAT:
 		t, ok := typ.(*types.FloatType)
 		if !ok {
@@ -304,27 +933,392 @@ func (gen *Generator) lowerBasicLit(goLit *ast.BasicLit) constant.Constant {
 		if err != nil {
 			panic(fmt.Errorf("unable to parse string literal %s; %v", s, err))
 		}
-		return constant.NewCharArrayFromString(s)
+		return gen.newStringConst(typ, s)
 	default:
 		panic(fmt.Errorf("support for literal of basic type %v not yet implemented", goLit.Kind))
 	}
 }
 
+// lowerConst lowers the Go constant value of the given type to LLVM IR.
+func (gen *Generator) lowerConst(goType gotypes.Type, val goconstant.Value) (constant.Constant, error) {
+	typ, err := gen.irType(goType)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if isComplex(goType) {
+		// Complex constant (or integer or floating-point constant of complex
+		// type), represented as a struct of the real and imaginary parts.
+		t, ok := typ.(*types.StructType)
+		if !ok {
+			return nil, errors.Errorf("invalid type of complex constant; expected *types.StructType, got %T", typ)
+		}
+		switch val.Kind() {
+		case goconstant.Int, goconstant.Float, goconstant.Complex:
+			partType := t.Fields[0].(*types.FloatType)
+			re := newFloatConst(partType, goconstant.Real(val))
+			im := newFloatConst(partType, goconstant.Imag(val))
+			c := constant.NewStruct(re, im)
+			c.Typ = t
+			return c, nil
+		}
+	}
+	switch val.Kind() {
+	case goconstant.Bool:
+		t, ok := typ.(*types.IntType)
+		if !ok {
+			return nil, errors.Errorf("invalid type of boolean constant; expected *types.IntType, got %T", typ)
+		}
+		var x int64
+		if goconstant.BoolVal(val) {
+			x = 1
+		}
+		return constant.NewInt(t, x), nil
+	case goconstant.String:
+		return gen.newStringConst(typ, goconstant.StringVal(val)), nil
+	case goconstant.Int, goconstant.Float:
+		switch t := typ.(type) {
+		case *types.IntType:
+			intVal := goconstant.ToInt(val)
+			if intVal.Kind() != goconstant.Int {
+				return nil, errors.Errorf("unable to represent constant %v of type %v as integer", val, goType)
+			}
+			x, ok := new(big.Int).SetString(intVal.ExactString(), 10)
+			if !ok {
+				return nil, errors.Errorf("unable to parse integer constant %v", intVal)
+			}
+			if !fitsInt(x, t.BitSize, isUnsigned(goType)) {
+				return nil, errors.Errorf("integer constant %v overflows type %v", intVal, goType)
+			}
+			return newBigInt(t, x), nil
+		case *types.FloatType:
+			return newFloatConst(t, val), nil
+		}
+	}
+	panic(fmt.Errorf("support for constant %v of type %v not yet implemented", val, goType))
+}
+
+// newFloatConst returns a new LLVM IR floating-point constant of the given type
+// with the value of the given Go integer or floating-point constant.
+func newFloatConst(t *types.FloatType, val goconstant.Value) *constant.Float {
+	x, _ := goconstant.Float64Val(goconstant.ToFloat(val))
+	if t.Kind == types.FloatKindFloat {
+		// Round to single precision.
+		x = float64(float32(x))
+	}
+	return constant.NewFloat(t, x)
+}
+
+// newStringConst returns a new LLVM IR constant of the given string type, with
+// a pointer to the string data and the length of the string. The string data
+// is stored in a global variable, shared by all string literals of identical
+// contents.
+func (gen *Generator) newStringConst(typ types.Type, s string) constant.Constant {
+	data, ok := gen.strLits[s]
+	if !ok {
+		name := fmt.Sprintf(".str.%d", len(gen.strLits))
+		data = gen.m.NewGlobalDef(name, constant.NewCharArrayFromString(s))
+		data.Immutable = true
+		data.Linkage = enum.LinkagePrivate
+		gen.strLits[s] = data
+	}
+	zero := constant.NewInt(types.I64, 0)
+	ptr := constant.NewGetElementPtr(data, zero, zero)
+	n := constant.NewInt(types.I64, int64(len(s)))
+	c := constant.NewStruct(ptr, n)
+	if t, ok := typ.(*types.StructType); ok {
+		// Use named string type (e.g. %untyped_string).
+		c.Typ = t
+	}
+	return c
+}
+
 // ### [ Helper functions ] ####################################################
 
 // lowerExprUse lowers the Go expression to LLVM IR, emitting to f. The value
-// stored at global variables is loaded to be ready for use.
+// stored at global and local variables is loaded to be ready for use.
 func (fgen *funcGen) lowerExprUse(goExpr ast.Expr) (value.Value, error) {
 	v, err := fgen.lowerExpr(goExpr)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	if v, ok := v.(*ir.Global); ok {
-		return fgen.cur.NewLoad(v), nil
+	if fgen.isAddressable(goExpr) {
+		v = fgen.newLoad(v)
+	}
+	// Record Go type to track the signedness of integer values.
+	if goType := fgen.gen.pkg.TypesInfo.TypeOf(goExpr); goType != nil {
+		fgen.goTypes[v] = fgen.gen.subst(goType)
 	}
 	return v, nil
 }
 
+// lowerExprAddr lowers the Go addressable expression to LLVM IR, emitting to f.
+// The returned value is a pointer to the memory of the operand.
+func (fgen *funcGen) lowerExprAddr(goExpr ast.Expr) (value.Value, error) {
+	switch goExpr := goExpr.(type) {
+	case *ast.Ident:
+		// Memory of local or global variable.
+		return fgen.lowerIdentExpr(goExpr)
+	case *ast.ParenExpr:
+		return fgen.lowerExprAddr(goExpr.X)
+	case *ast.IndexExpr:
+		return fgen.lowerIndexAddr(goExpr)
+	case *ast.SelectorExpr:
+		return fgen.lowerFieldAddr(goExpr)
+	case *ast.StarExpr:
+		// Memory of pointee.
+		return fgen.lowerStarExpr(goExpr)
+	default:
+		panic(fmt.Errorf("support for address of expression %T not yet implemented", goExpr))
+	}
+}
+
+// lowerFieldAddr lowers the Go field selector expression to LLVM IR, emitting
+// to f. The returned value is a pointer to the memory of the selected field.
+func (fgen *funcGen) lowerFieldAddr(goSelExpr *ast.SelectorExpr) (value.Value, error) {
+	info := fgen.gen.pkg.TypesInfo
+	sel, ok := info.Selections[goSelExpr]
+	if !ok || sel.Kind() != gotypes.FieldVal {
+		return nil, errors.Errorf("invalid selector expression `%v`; expected field selector", goSelExpr.Sel)
+	}
+	// Memory of struct.
+	goType := info.TypeOf(goSelExpr.X)
+	var addr value.Value
+	var err error
+	if goPtrType, ok := goType.Underlying().(*gotypes.Pointer); ok {
+		// Implicit dereference of struct pointer (e.g. `p.X` for `(*p).X`).
+		addr, err = fgen.lowerExprUse(goSelExpr.X)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		fgen.checkNil(addr)
+		goType = goPtrType.Elem()
+	} else {
+		addr, err = fgen.lowerExprAddr(goSelExpr.X)
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// Follow the path of embedded fields to the selected field.
+	zero := constant.NewInt(types.I32, 0)
+	for i, index := range sel.Index() {
+		if goPtrType, ok := goType.Underlying().(*gotypes.Pointer); ok && i > 0 {
+			// Implicit dereference of embedded struct pointer.
+			addr = fgen.newLoad(addr)
+			fgen.checkNil(addr)
+			goType = goPtrType.Elem()
+		}
+		goStructType, ok := goType.Underlying().(*gotypes.Struct)
+		if !ok {
+			return nil, errors.Errorf("invalid operand type of field selector; expected struct, got %v", goType)
+		}
+		addr = fgen.cur.NewGetElementPtr(addr, zero, constant.NewInt(types.I32, int64(index)))
+		goType = goStructType.Field(index).Type()
+	}
+	return addr, nil
+}
+
+// lowerFieldValue lowers the Go field selector expression of a non-addressable
+// struct value (e.g. `f().X`) to LLVM IR, emitting to f.
+func (fgen *funcGen) lowerFieldValue(goSelExpr *ast.SelectorExpr) (value.Value, error) {
+	info := fgen.gen.pkg.TypesInfo
+	sel := info.Selections[goSelExpr]
+	v, err := fgen.lowerExprUse(goSelExpr.X)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// Follow the path of embedded fields to the selected field. The struct value
+	// is held in v until an embedded struct pointer is reached, after which v
+	// holds a pointer to the memory of the embedded struct.
+	goType := info.TypeOf(goSelExpr.X)
+	indirect := false
+	zero := constant.NewInt(types.I32, 0)
+	for _, index := range sel.Index() {
+		if goPtrType, ok := goType.Underlying().(*gotypes.Pointer); ok {
+			// Implicit dereference of embedded struct pointer.
+			if indirect {
+				v = fgen.newLoad(v)
+			}
+			fgen.checkNil(v)
+			indirect = true
+			goType = goPtrType.Elem()
+		}
+		goStructType, ok := goType.Underlying().(*gotypes.Struct)
+		if !ok {
+			return nil, errors.Errorf("invalid operand type of field selector; expected struct, got %v", goType)
+		}
+		if indirect {
+			v = fgen.cur.NewGetElementPtr(v, zero, constant.NewInt(types.I32, int64(index)))
+		} else {
+			v = fgen.cur.NewExtractValue(v, uint64(index))
+		}
+		goType = goStructType.Field(index).Type()
+	}
+	if indirect {
+		return fgen.newLoad(v), nil
+	}
+	return v, nil
+}
+
+// lowerExprAddrUse lowers the Go addressable expression to LLVM IR, emitting to
+// f. The returned values are the pointer to the memory of the operand and the
+// value loaded from memory, for read-modify-write operations (e.g. `x++`).
+func (fgen *funcGen) lowerExprAddrUse(goExpr ast.Expr) (addr, v value.Value, err error) {
+	addr, err = fgen.lowerExprAddr(goExpr)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	v = fgen.newLoad(addr)
+	fgen.goTypes[v] = fgen.gen.subst(fgen.gen.pkg.TypesInfo.TypeOf(goExpr))
+	return addr, v, nil
+}
+
+// lowerIndexAddr lowers the Go index expression of an addressable element to
+// LLVM IR, emitting to f. The returned value is a pointer to the memory of the
+// indexed element of the slice, array pointer or addressable array.
+func (fgen *funcGen) lowerIndexAddr(goExpr *ast.IndexExpr) (value.Value, error) {
+	zero := constant.NewInt(types.I64, 0)
+	goType := fgen.gen.subst(fgen.gen.pkg.TypesInfo.TypeOf(goExpr.X))
+	switch goType := goType.Underlying().(type) {
+	case *gotypes.Slice:
+		s, err := fgen.lowerExprUse(goExpr.X)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		index, err := fgen.lowerSliceIndex(goExpr.Index, nil)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		data := fgen.cur.NewExtractValue(s, 0)
+		length := fgen.cur.NewExtractValue(s, 1)
+		fgen.checkIndex(index, length)
+		return fgen.cur.NewGetElementPtr(data, index), nil
+	case *gotypes.Pointer:
+		// Implicit dereference of array pointer (e.g. `p[i]` for `(*p)[i]`).
+		goArrayType, ok := goType.Elem().Underlying().(*gotypes.Array)
+		if !ok {
+			return nil, errors.Errorf("invalid operand type of index expression; expected array pointer, got %v", goType)
+		}
+		array, err := fgen.lowerExprUse(goExpr.X)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		index, err := fgen.lowerSliceIndex(goExpr.Index, nil)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		fgen.checkNil(array)
+		fgen.checkIndex(index, constant.NewInt(types.I64, goArrayType.Len()))
+		return fgen.cur.NewGetElementPtr(array, zero, index), nil
+	case *gotypes.Array:
+		array, err := fgen.lowerExprAddr(goExpr.X)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		index, err := fgen.lowerSliceIndex(goExpr.Index, nil)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		fgen.checkIndex(index, constant.NewInt(types.I64, goType.Len()))
+		return fgen.cur.NewGetElementPtr(array, zero, index), nil
+	default:
+		return nil, errors.Errorf("invalid operand type of addressable index expression; expected slice, array pointer or array, got %v", goType)
+	}
+}
+
+// lowerIndexValue lowers the Go index expression of a non-addressable element
+// (e.g. byte of string) to LLVM IR, emitting to f.
+func (fgen *funcGen) lowerIndexValue(goExpr *ast.IndexExpr) (value.Value, error) {
+	x, err := fgen.lowerExprUse(goExpr.X)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	index, err := fgen.lowerSliceIndex(goExpr.Index, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	goType := fgen.gen.subst(fgen.gen.pkg.TypesInfo.TypeOf(goExpr.X))
+	switch goType := goType.Underlying().(type) {
+	case *gotypes.Basic:
+		if goType.Info()&gotypes.IsString == 0 {
+			return nil, errors.Errorf("invalid operand type of index expression; expected string, got %v", goType)
+		}
+		data := fgen.cur.NewExtractValue(x, 0)
+		length := fgen.cur.NewExtractValue(x, 1)
+		fgen.checkIndex(index, length)
+		elem := fgen.cur.NewGetElementPtr(data, index)
+		return fgen.newLoad(elem), nil
+	case *gotypes.Array:
+		// Array value (e.g. result of function call).
+		if i, ok := index.(*constant.Int); ok && inRange(i, constant.NewInt(types.I64, goType.Len())) {
+			return fgen.cur.NewExtractValue(x, i.X.Uint64()), nil
+		}
+		// Spill to memory to index by a non-constant index.
+		array := fgen.newLocal(x.Type())
+		fgen.newStore(x, array)
+		fgen.checkIndex(index, constant.NewInt(types.I64, goType.Len()))
+		zero := constant.NewInt(types.I64, 0)
+		elem := fgen.cur.NewGetElementPtr(array, zero, index)
+		return fgen.newLoad(elem), nil
+	default:
+		return nil, errors.Errorf("invalid operand type of index expression; expected string or array, got %v", goType)
+	}
+}
+
+// isNil reports whether the given Go expression is the predeclared nil value.
+func (fgen *funcGen) isNil(goExpr ast.Expr) bool {
+	return fgen.gen.pkg.TypesInfo.Types[goExpr].IsNil()
+}
+
+// isInstance reports whether the given Go identifier refers to an instance of a
+// generic function.
+func (fgen *funcGen) isInstance(goIdent *ast.Ident) bool {
+	_, ok := fgen.gen.pkg.TypesInfo.Instances[goIdent]
+	return ok
+}
+
+// isAddressable reports whether the given Go expression is addressable, in
+// which case lowerExpr returns a pointer to the memory of the operand.
+func (fgen *funcGen) isAddressable(goExpr ast.Expr) bool {
+	info := fgen.gen.pkg.TypesInfo
+	if tv, ok := info.Types[goExpr]; ok && tv.Value != nil {
+		// Constant expression.
+		return false
+	}
+	switch goExpr := goExpr.(type) {
+	case *ast.Ident:
+		// Local or global variable.
+		_, ok := info.ObjectOf(goExpr).(*gotypes.Var)
+		return ok
+	case *ast.ParenExpr:
+		return fgen.isAddressable(goExpr.X)
+	case *ast.StarExpr:
+		// Pointer indirection.
+		return true
+	case *ast.SelectorExpr:
+		// Field selector of addressable struct or struct pointer.
+		sel, ok := info.Selections[goExpr]
+		if !ok || sel.Kind() != gotypes.FieldVal {
+			return false
+		}
+		if _, ok := info.TypeOf(goExpr.X).Underlying().(*gotypes.Pointer); ok {
+			return true
+		}
+		return sel.Indirect() || fgen.isAddressable(goExpr.X)
+	case *ast.IndexExpr:
+		// Element of slice, array pointer or addressable array.
+		goType := fgen.gen.subst(info.TypeOf(goExpr.X))
+		switch goType.Underlying().(type) {
+		case *gotypes.Slice, *gotypes.Pointer:
+			return true
+		case *gotypes.Array:
+			return fgen.isAddressable(goExpr.X)
+		}
+		return false
+	default:
+		return false
+	}
+}
+
 // lowerExprs lowers the given Go expressions to LLVM IR, emitting to f.
 func (fgen *funcGen) lowerExprs(goExprs []ast.Expr) ([]value.Value, error) {
 	var vs []value.Value
@@ -338,6 +1332,21 @@ func (fgen *funcGen) lowerExprs(goExprs []ast.Expr) ([]value.Value, error) {
 	return vs, nil
 }
 
+// lowerSliceIndex lowers the Go slice index expression to LLVM IR, emitting to
+// f. The index is converted to a 64-bit integer. The default value is used if
+// the index is omitted.
+func (fgen *funcGen) lowerSliceIndex(goIndex ast.Expr, def value.Value) (value.Value, error) {
+	if goIndex == nil {
+		return def, nil
+	}
+	index, err := fgen.lowerExprUse(goIndex)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	goIndexType := fgen.gen.pkg.TypesInfo.TypeOf(goIndex)
+	return fgen.convert(index, goIndexType, gotypes.Typ[gotypes.Int64])
+}
+
 // isIntOrIntVectorType reports whether the given type is an integer scalar or
 // integer vector type.
 func isIntOrIntVectorType(t types.Type) bool {
@@ -412,6 +1421,42 @@ func allOnes(t types.Type) (constant.Constant, error) {
 	return elem, nil
 }
 
+// zeroValue returns the zero value of the given type.
+func zeroValue(t types.Type) constant.Constant {
+	if t, ok := t.(*types.PointerType); ok {
+		return constant.NewNull(t)
+	}
+	return constant.NewZeroInitializer(t)
+}
+
+// fitsInt reports whether the given integer is representable by a signed or
+// unsigned integer type of the given bit size.
+func fitsInt(x *big.Int, bitSize uint64, unsigned bool) bool {
+	if unsigned {
+		return x.Sign() >= 0 && uint64(x.BitLen()) <= bitSize
+	}
+	// Signed range: -2^(n-1) <= x < 2^(n-1).
+	if x.Sign() >= 0 {
+		return uint64(x.BitLen()) < bitSize
+	}
+	// Two's complement of negative x fits if -x-1 < 2^(n-1).
+	y := new(big.Int).Neg(x)
+	y.Sub(y, big.NewInt(1))
+	return uint64(y.BitLen()) < bitSize
+}
+
+// newBigInt returns a new LLVM IR integer constant of the given type based on
+// the arbitrary precision integer x. Values exceeding the signed range of the
+// integer type (e.g. uint64 values above math.MaxInt64) are stored in two's
+// complement form, as LLVM IR integers are signless.
+func newBigInt(t *types.IntType, x *big.Int) *constant.Int {
+	if x.Sign() > 0 && uint64(x.BitLen()) >= t.BitSize {
+		// x - 2^n
+		x = new(big.Int).Sub(x, new(big.Int).Lsh(big.NewInt(1), uint(t.BitSize)))
+	}
+	return &constant.Int{Typ: t, X: x}
+}
+
 // bitSize returns the bit size of the given integer scalar or integer vector
 // type.
 func bitSize(t types.Type) (uint64, bool) {
@@ -0,0 +1,453 @@
+package lower
+
+import (
+	goconstant "go/constant"
+	gotypes "go/types"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestFloatEqualNaN(t *testing.T) {
+	const src = `package p
+
+func eq(x float64) bool {
+	return x == x
+}
+
+func ne(x float64) bool {
+	return x != x
+}
+`
+	module := lowerSource(t, src)
+	// Ordered equality is false for NaN operands, and unordered inequality is
+	// true.
+	wantIR(t, mustFuncDef(t, module, "eq"), "fcmp oeq double")
+	wantIR(t, mustFuncDef(t, module, "ne"), "fcmp une double")
+	rejectIR(t, module, "icmp")
+}
+
+func TestFloatRelational(t *testing.T) {
+	const src = `package p
+
+func lss(x, y float32) bool { return x < y }
+func leq(x, y float32) bool { return x <= y }
+func gtr(x, y float32) bool { return x > y }
+func geq(x, y float32) bool { return x >= y }
+`
+	module := lowerSource(t, src)
+	wantIR(t, mustFuncDef(t, module, "lss"), "fcmp olt float")
+	wantIR(t, mustFuncDef(t, module, "leq"), "fcmp ole float")
+	wantIR(t, mustFuncDef(t, module, "gtr"), "fcmp ogt float")
+	wantIR(t, mustFuncDef(t, module, "geq"), "fcmp oge float")
+	rejectIR(t, module, "icmp")
+}
+
+func TestPointerOrderingError(t *testing.T) {
+	// Pointers are not ordered, which is reported by the type-checker. The
+	// generator reports an error rather than emitting invalid LLVM IR.
+	const src = `package p
+
+func f(p, q *int) {
+	_ = p < q
+}
+`
+	errs := lowerInvalid(t, src)
+	wantError(t, errs, "pointers may only be compared for equality, got *int")
+}
+
+func TestUint64MaxConst(t *testing.T) {
+	const src = `package p
+
+var x uint64 = 0xFFFFFFFFFFFFFFFF
+`
+	module := lowerSource(t, src)
+	// The bit pattern of all ones; LLVM IR integer constants are printed as
+	// signed values.
+	wantIR(t, module, "@x = global i64 -1")
+}
+
+func TestArraySlice(t *testing.T) {
+	const src = `package p
+
+func f() []int {
+	var a [3]int
+	return a[:]
+}
+`
+	def := mustFuncDef(t, lowerSource(t, src), "f")
+	// Slice over the array memory, of length and capacity 3.
+	wantIR(t, def, "getelementptr [3 x i64], [3 x i64]*", "sub i64 3, 0")
+	wantCount(t, def, "sub i64 3, 0", 2)
+}
+
+func TestRecursiveCall(t *testing.T) {
+	const src = `package p
+
+func fib(n int) int {
+	if n < 2 {
+		return n
+	}
+	return fib(n-1) + fib(n-2)
+}
+`
+	def := mustFuncDef(t, lowerSource(t, src), "fib")
+	wantCount(t, def, "call i64 @fib(", 2)
+}
+
+func TestFieldAddrArg(t *testing.T) {
+	const src = `package p
+
+type P struct {
+	X, Y int
+}
+
+func inc(x *int) {
+	*x++
+}
+
+func f() int {
+	var p P
+	inc(&p.Y)
+	return p.Y
+}
+`
+	def := mustFuncDef(t, lowerSource(t, src), "f")
+	// The address of the field within the memory of p is passed, so that inc
+	// mutates the field.
+	wantIR(t, def, "getelementptr %P, %P* %", "i32 0, i32 1", "call void @inc(i64* %")
+}
+
+func TestVariadicIfaceArgs(t *testing.T) {
+	const src = `package p
+
+func printf(args ...interface{}) {}
+
+func f() {
+	printf(1, "s")
+}
+`
+	def := mustFuncDef(t, lowerSource(t, src), "f")
+	// Each argument is boxed with the type descriptor of its default type.
+	wantIR(t, def, "@toy.type.int", "@toy.type.string")
+	wantCount(t, def, "call i8* @toy.alloc(", 2)
+}
+
+func TestFuncNil(t *testing.T) {
+	const src = `package p
+
+func f(g func() int) int {
+	if g == nil {
+		return 0
+	}
+	return g()
+}
+`
+	def := mustFuncDef(t, lowerSource(t, src), "f")
+	// Function values are pointers to closure objects; nil is the null pointer.
+	wantIR(t, def, "icmp eq", "null")
+}
+
+func TestMultiValueArgs(t *testing.T) {
+	const src = `package p
+
+func f() (int, int) { return 1, 2 }
+
+func g(a, b int) int { return a + b }
+
+func h() int {
+	return g(f())
+}
+`
+	def := mustFuncDef(t, lowerSource(t, src), "h")
+	// The results of f are passed as the arguments of g.
+	wantIR(t, def, "call { i64, i64 } @f()", "call i64 @g(")
+	wantCount(t, def, "extractvalue", 2)
+}
+
+func TestClosureCapture(t *testing.T) {
+	const src = `package p
+
+var g int
+
+func f(x int) func() int {
+	return func() int {
+		return g + x
+	}
+}
+`
+	module := lowerSource(t, src)
+	lit := mustFuncDef(t, module, "f.func1")
+	// The global variable is accessed directly, and the captured local through
+	// the closure object passed as hidden first argument.
+	wantIR(t, lit, "@g", "%ctx")
+	def := mustFuncDef(t, module, "f")
+	// The closure object holds the function and a pointer to the captured
+	// local.
+	wantIR(t, def, "@f.func1")
+	rejectIR(t, def, "@g")
+}
+
+func TestAlignedLoad(t *testing.T) {
+	const src = `package p
+
+type T struct {
+	a int8
+	b float64
+}
+
+func f(t *T, x int32) (float64, int32) {
+	return t.b, x
+}
+`
+	def := mustFuncDef(t, lowerSource(t, src), "f")
+	// Memory accesses carry the ABI alignment of the accessed type.
+	wantIR(t, def, "load double, double* ", "load i32, i32* ")
+	wantIR(t, def, ", align 8", ", align 4")
+}
+
+func TestIntConstOverflow(t *testing.T) {
+	pkg := loadPackage(t, map[string]string{"p.go": "package p\n"}, true)
+	gen := NewGenerator(func(err error) {}, pkg)
+	golden := []struct {
+		goType *gotypes.Basic
+		val    int64
+		want   bool // overflow
+	}{
+		{goType: gotypes.Typ[gotypes.Int8], val: 127, want: false},
+		{goType: gotypes.Typ[gotypes.Int8], val: 128, want: true},
+		{goType: gotypes.Typ[gotypes.Int8], val: -128, want: false},
+		{goType: gotypes.Typ[gotypes.Int8], val: -129, want: true},
+		{goType: gotypes.Typ[gotypes.Uint8], val: 255, want: false},
+		{goType: gotypes.Typ[gotypes.Uint8], val: 256, want: true},
+		{goType: gotypes.Typ[gotypes.Uint16], val: -1, want: true},
+	}
+	for _, g := range golden {
+		_, err := gen.lowerConst(g.goType, goconstant.MakeInt64(g.val))
+		if got := err != nil; got != g.want {
+			t.Errorf("overflow mismatch of constant %d of type %v; expected %v, got %v (err=%v)", g.val, g.goType, g.want, got, err)
+		}
+	}
+	// Unsigned 64-bit integers exceed the signed range of int64.
+	if !fitsInt(new(big.Int).SetUint64(1<<64-1), 64, true) {
+		t.Errorf("expected math.MaxUint64 to fit in uint64")
+	}
+	if fitsInt(new(big.Int).SetUint64(1<<63), 64, false) {
+		t.Errorf("expected 1<<63 to overflow int64")
+	}
+}
+
+func TestUnsignedIntermediate(t *testing.T) {
+	const src = `package p
+
+func div(x, y, z uint32) uint32 {
+	return (x + y) / z
+}
+
+func shr(x, y uint32) uint32 {
+	return (x * y) >> 1
+}
+
+func sdiv(x, y, z int32) int32 {
+	return (x + y) / z
+}
+`
+	module := lowerSource(t, src)
+	// Intermediate results retain the signedness of their Go type.
+	wantIR(t, mustFuncDef(t, module, "div"), "udiv i32")
+	wantIR(t, mustFuncDef(t, module, "shr"), "lshr i32")
+	wantIR(t, mustFuncDef(t, module, "sdiv"), "sdiv i32")
+}
+
+func TestInt16Const(t *testing.T) {
+	const src = `package p
+
+func f(x int16) int16 {
+	return x*3 + 1
+}
+`
+	def := mustFuncDef(t, lowerSource(t, src), "f")
+	// Untyped constant operands take the width of the typed operand.
+	wantIR(t, def, "mul i16 ", ", 3\n", "add i16 ", ", 1\n")
+	rejectIR(t, def, "i64", "sext", "trunc")
+}
+
+func TestConstLenCompare(t *testing.T) {
+	const src = `package p
+
+func nonEmpty() bool {
+	return len("abc") > 0
+}
+
+func empty() bool {
+	return len("") == 0
+}
+`
+	module := lowerSource(t, src)
+	for _, name := range []string{"nonEmpty", "empty"} {
+		def := mustFuncDef(t, module, name)
+		wantIR(t, def, "ret i1 true")
+		rejectIR(t, def, "icmp", "extractvalue")
+	}
+}
+
+func TestUnsignedDiv(t *testing.T) {
+	const src = `package p
+
+func f(a, b uint) (uint, uint) {
+	return a / b, a % b
+}
+
+func g(a, b int) (int, int) {
+	return a / b, a % b
+}
+`
+	module := lowerSource(t, src)
+	f := mustFuncDef(t, module, "f")
+	wantIR(t, f, "udiv i64", "urem i64")
+	rejectIR(t, f, "sdiv", "srem")
+	g := mustFuncDef(t, module, "g")
+	wantIR(t, g, "sdiv i64", "srem i64")
+	rejectIR(t, g, "udiv", "urem")
+}
+
+func TestUnsignedCompare(t *testing.T) {
+	const src = `package p
+
+func lt(a, b uint) bool {
+	return a < b
+}
+
+func ge(a, b uint8) bool {
+	return a >= b
+}
+
+func slt(a, b int) bool {
+	return a < b
+}
+
+func eq(a, b uint) bool {
+	return a == b
+}
+
+func ne(a, b uint) bool {
+	return a != b
+}
+`
+	module := lowerSource(t, src)
+	// 1<<63 is less than 1 for signed comparison, but not for unsigned.
+	wantIR(t, mustFuncDef(t, module, "lt"), "icmp ult i64")
+	wantIR(t, mustFuncDef(t, module, "ge"), "icmp uge i8")
+	wantIR(t, mustFuncDef(t, module, "slt"), "icmp slt i64")
+	// Equality is sign-agnostic.
+	wantIR(t, mustFuncDef(t, module, "eq"), "icmp eq i64")
+	wantIR(t, mustFuncDef(t, module, "ne"), "icmp ne i64")
+}
+
+func TestSignedShift(t *testing.T) {
+	const src = `package p
+
+func sar() int {
+	x := -8
+	return x >> 1 // -4
+}
+
+func shr(x uint) uint {
+	return x >> 1
+}
+
+func folded() int {
+	return -8 >> 1
+}
+`
+	module := lowerSource(t, src)
+	// Signed values are shifted with sign extension.
+	sar := mustFuncDef(t, module, "sar")
+	wantIR(t, sar, "i64 -8", "ashr i64")
+	rejectIR(t, sar, "lshr")
+	wantIR(t, mustFuncDef(t, module, "shr"), "lshr i64")
+	wantIR(t, mustFuncDef(t, module, "folded"), "ret i64 -4")
+}
+
+func TestShortCircuit(t *testing.T) {
+	const src = `package p
+
+func g() bool { return true }
+
+func and(x int) bool {
+	return x > 0 && g()
+}
+
+func or(x int) bool {
+	return x > 0 || g()
+}
+`
+	module := lowerSource(t, src)
+	for _, name := range []string{"and", "or"} {
+		def := mustFuncDef(t, module, name)
+		// The right operand is only evaluated after branching on the left
+		// operand.
+		br := strings.Index(def, "br i1 ")
+		call := strings.Index(def, "call i1 @g()")
+		if br == -1 || call == -1 || call < br {
+			t.Errorf("expected call of right operand after conditional branch in:\n%s", def)
+		}
+		wantIR(t, def, "phi i1 ")
+		rejectIR(t, def, "and i1", "or i1")
+	}
+	wantIR(t, mustFuncDef(t, module, "and"), "phi i1 [ false, ")
+	wantIR(t, mustFuncDef(t, module, "or"), "phi i1 [ true, ")
+}
+
+func TestGlobalConstInit(t *testing.T) {
+	const src = `package p
+
+var y float64 = 1
+
+var n int32 = 1 << 4
+`
+	module := lowerSource(t, src)
+	// Constants are represented by the declared type of the global variable.
+	wantIR(t, module, "@y = global double 1.0", "@n = global i32 16")
+	errs := lowerErrors(t, `package p
+
+var e interface{} = 1
+`)
+	wantError(t, errs, "support for constant initializer of global variable of non-basic type interface{} not yet implemented")
+}
+
+func TestSliceLitElemAddr(t *testing.T) {
+	const src = `package p
+
+func f() int {
+	p := &[]int{1, 2, 3}[1]
+	*p = 5
+	return *p
+}
+`
+	def := mustFuncDef(t, lowerSource(t, src), "f")
+	// The element pointer points into the backing array allocated on the heap
+	// by the literal, through which the element is updated.
+	wantIR(t, def, "call i8* @toy.alloc(", "extractvalue { i64*, i64, i64 } ", "getelementptr i64, i64* ", "store i64 5, i64* ")
+	wantCount(t, def, "alloca i64*", 1)
+}
+
+func TestNamedShiftConst(t *testing.T) {
+	const src = `package p
+
+const Shift = 3
+
+func folded() int {
+	return 1 << Shift
+}
+
+func shl(x int) int {
+	return x << Shift
+}
+`
+	module := lowerSource(t, src)
+	folded := mustFuncDef(t, module, "folded")
+	wantIR(t, folded, "ret i64 8")
+	rejectIR(t, folded, "shl")
+	wantIR(t, mustFuncDef(t, module, "shl"), "shl i64 ", ", 3\n")
+}
@@ -1,9 +1,11 @@
 package lower
 
 import (
+	"go/ast"
 	gotypes "go/types"
 
 	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/value"
 )
 
 // funcGen is an LLVM IR generator for a given function.
@@ -12,16 +14,89 @@ type funcGen struct {
 	gen *Generator
 	// Function scope.
 	scope *gotypes.Scope
+	// Go function signature.
+	sig *gotypes.Signature
 	// LLVM IR function being generated.
 	f *ir.Function
 	// Current basic block being generated.
 	cur *ir.BasicBlock
+	// locals maps from Go local variable objects (including function
+	// parameters) to the LLVM IR stack memory allocated for them.
+	locals map[gotypes.Object]value.Value
+	// Stack of branch targets of enclosing for, range and switch statements;
+	// innermost last.
+	targets []*branchTarget
+	// labels maps from label name to the basic block of the labeled statement.
+	labels map[string]*ir.BasicBlock
+	// stmtLabels maps from labeled statements to their label name.
+	stmtLabels map[ast.Stmt]string
+	// goTypes maps from lowered LLVM IR values to their Go type, to recover the
+	// signedness of integer values, as LLVM IR integer types are sign-agnostic.
+	goTypes map[value.Value]gotypes.Type
+	// Number of function literals lowered within the function; used to name
+	// the functions of function literals.
+	funcLits int
+	// Local variables captured by function literals within the function, which
+	// are allocated on the heap as they may outlive the function.
+	captured map[gotypes.Object]bool
+	// Pointer to the closure object passed as hidden first parameter of
+	// functions of function literals; or nil for top-level functions.
+	ctx *ir.Param
+	// Variables captured by the function literal, in order of the fields of
+	// the closure object.
+	captures []*gotypes.Var
+}
+
+// branchTarget holds the target basic blocks of break and continue statements
+// within a for, range or switch statement.
+type branchTarget struct {
+	// Label of the statement; or empty if unlabeled.
+	label string
+	// Target of break statements; the follow basic block of the statement.
+	breakBlock *ir.BasicBlock
+	// Target of continue statements; the post basic block of for and range
+	// statements, and nil for switch statements.
+	continueBlock *ir.BasicBlock
+}
+
+// pushTarget pushes the branch target of the given for, range or switch
+// statement to the stack of enclosing statements. The returned function pops
+// the target.
+func (fgen *funcGen) pushTarget(goStmt ast.Stmt, breakBlock, continueBlock *ir.BasicBlock) (pop func()) {
+	target := &branchTarget{
+		label:         fgen.stmtLabels[goStmt],
+		breakBlock:    breakBlock,
+		continueBlock: continueBlock,
+	}
+	fgen.targets = append(fgen.targets, target)
+	return func() {
+		fgen.targets = fgen.targets[:len(fgen.targets)-1]
+	}
+}
+
+// isUnsignedValue reports whether the given lowered LLVM IR value is of unsigned
+// integer Go type.
+func (fgen *funcGen) isUnsignedValue(v value.Value) bool {
+	goType, ok := fgen.goTypes[v]
+	return ok && isUnsigned(goType)
+}
+
+// isStringValue reports whether the given lowered LLVM IR value is of Go string
+// type.
+func (fgen *funcGen) isStringValue(v value.Value) bool {
+	goType, ok := fgen.goTypes[v]
+	return ok && isString(goType)
 }
 
 // newFuncGen returns a new LLVM IR function generator for the given module
 // generator.
 func (gen *Generator) newFuncGen() *funcGen {
 	return &funcGen{
-		gen: gen,
+		gen:        gen,
+		locals:     make(map[gotypes.Object]value.Value),
+		labels:     make(map[string]*ir.BasicBlock),
+		stmtLabels: make(map[ast.Stmt]string),
+		goTypes:    make(map[value.Value]gotypes.Type),
+		captured:   make(map[gotypes.Object]bool),
 	}
 }
@@ -1,6 +1,7 @@
 package lower
 
 import (
+	"go/ast"
 	gotypes "go/types"
 
 	"github.com/llir/llvm/ir"
@@ -11,6 +12,13 @@ import (
 // Generator keeps track of top-level entities when translating from Go AST to
 // LLVM IR representation.
 type Generator struct {
+	// BoundsCheck specifies whether to emit bounds checks of index and slice
+	// expressions; enabled by default.
+	BoundsCheck bool
+	// NilCheck specifies whether to emit nil checks of pointers before memory is
+	// accessed through them; disabled by default.
+	NilCheck bool
+
 	// Error handler used to report errors encountered during compilation.
 	eh func(error)
 	// Go package being compiled.
@@ -31,6 +39,34 @@ type Generator struct {
 	// funcs maps from global identifier (without '@' prefix) to function
 	// declarations and defintions.
 	funcs map[string]*ir.Function
+	// genericFuncs maps from generic function and method objects to generic
+	// function declarations.
+	genericFuncs map[*gotypes.Func]*ast.FuncDecl
+	// instances holds generic function instances whose bodies have yet to be
+	// lowered.
+	instances []*funcInstance
+	// typeArgs maps from type parameters to concrete type arguments of the
+	// generic function instance being lowered.
+	typeArgs map[*gotypes.TypeParam]gotypes.Type
+	// strLits maps from string literal contents to global variable definitions
+	// holding the string data.
+	strLits map[string]*ir.Global
+	// typeDescs maps from global identifier (without '@' prefix) to type
+	// descriptors.
+	typeDescs map[string]*ir.Global
+	// itabs maps from global identifier (without '@' prefix) to itables.
+	itabs map[string]*ir.Global
+	// runtimeFuncs maps from global identifier (without '@' prefix) to runtime
+	// helper and LLVM intrinsic function declarations.
+	runtimeFuncs map[string]*ir.Function
+	// initFuncs maps from init function declarations to function definitions.
+	initFuncs map[*ast.FuncDecl]*ir.Function
+	// inits holds the init function definitions of the package, in order of
+	// declaration.
+	inits []*ir.Function
+	// packed records the struct type definitions marked by the //toy:packed
+	// directive.
+	packed map[*gotypes.TypeName]bool
 }
 
 // NewGenerator returns a new generator for lowering the source code of the Go
@@ -38,13 +74,21 @@ type Generator struct {
 // encountered during compilation.
 func NewGenerator(eh func(error), pkg *packages.Package) *Generator {
 	gen := &Generator{
-		eh:       eh,
-		pkg:      pkg,
-		scope:    pkg.Types.Scope(),
-		m:        ir.NewModule(),
-		typeDefs: make(map[string]types.Type),
-		globals:  make(map[string]*ir.Global),
-		funcs:    make(map[string]*ir.Function),
+		BoundsCheck:  true,
+		eh:           eh,
+		pkg:          pkg,
+		scope:        pkg.Types.Scope(),
+		m:            ir.NewModule(),
+		typeDefs:     make(map[string]types.Type),
+		globals:      make(map[string]*ir.Global),
+		funcs:        make(map[string]*ir.Function),
+		genericFuncs: make(map[*gotypes.Func]*ast.FuncDecl),
+		strLits:      make(map[string]*ir.Global),
+		typeDescs:    make(map[string]*ir.Global),
+		itabs:        make(map[string]*ir.Global),
+		runtimeFuncs: make(map[string]*ir.Function),
+		initFuncs:    make(map[*ast.FuncDecl]*ir.Function),
+		packed:       make(map[*gotypes.TypeName]bool),
 	}
 	return gen
 }
@@ -0,0 +1,235 @@
+package lower
+
+import (
+	"fmt"
+	"go/ast"
+	gotypes "go/types"
+	"strings"
+
+	"github.com/llir/llvm/ir"
+	"github.com/pkg/errors"
+)
+
+// funcInstance is an instance of a generic Go function or of a method of a
+// generic Go type, which is lowered by monomorphization; i.e. by lowering the
+// body of the generic function with type parameters substituted for concrete
+// type arguments.
+type funcInstance struct {
+	// Generic function or method declaration.
+	goFuncDecl *ast.FuncDecl
+	// Mapping from type parameters to concrete type arguments.
+	typeArgs map[*gotypes.TypeParam]gotypes.Type
+	// LLVM IR function of the instance.
+	f *ir.Function
+}
+
+// instantiate returns the LLVM IR function of the instance of the generic Go
+// function with the given type arguments, adding the function to the module on
+// first use. The function body is lowered by lowerInstances.
+func (gen *Generator) instantiate(obj *gotypes.Func, goTypeArgs *gotypes.TypeList) (*ir.Function, error) {
+	obj = obj.Origin()
+	goFuncDecl, ok := gen.genericFuncs[obj]
+	if !ok {
+		return nil, errors.Errorf("unable to locate generic function declaration %q", obj.Name())
+	}
+	// Type arguments may refer to type parameters of the enclosing generic
+	// function (e.g. `Max[T](a, b)` in the body of a generic function), which
+	// are substituted for their concrete type arguments.
+	goTypeParams := obj.Type().(*gotypes.Signature).TypeParams()
+	typeArgs := make(map[*gotypes.TypeParam]gotypes.Type)
+	var argNames []string
+	for i := 0; i < goTypeArgs.Len(); i++ {
+		goTypeArg := gen.subst(goTypeArgs.At(i))
+		typeArgs[goTypeParams.At(i)] = goTypeArg
+		argNames = append(argNames, gotypes.TypeString(goTypeArg, gen.qualifier))
+	}
+	// Mangle function name (e.g. "Max[int]").
+	funcName := fmt.Sprintf("%s[%s]", obj.Name(), strings.Join(argNames, ","))
+	return gen.newInstance(funcName, goFuncDecl, typeArgs), nil
+}
+
+// instantiateMethod returns the LLVM IR function of the method of the generic Go
+// type instance (e.g. `Push` of `Stack[int]`), adding the function to the
+// module on first use. The function body is lowered by lowerInstances.
+func (gen *Generator) instantiateMethod(fn *gotypes.Func) (*ir.Function, error) {
+	obj := fn.Origin()
+	goFuncDecl, ok := gen.genericFuncs[obj]
+	if !ok {
+		return nil, errors.Errorf("unable to locate generic method declaration %q", obj.Name())
+	}
+	// Type arguments of the receiver base type may refer to type parameters of
+	// the enclosing generic function, which are substituted for their concrete
+	// type arguments.
+	goRecvType, ok := recvBaseType(gen.subst(fn.Type().(*gotypes.Signature).Recv().Type()))
+	if !ok {
+		return nil, errors.Errorf("invalid receiver type of generic method %q; expected named type", fn.Name())
+	}
+	goTypeParams := obj.Type().(*gotypes.Signature).RecvTypeParams()
+	goTypeArgs := goRecvType.TypeArgs()
+	typeArgs := make(map[*gotypes.TypeParam]gotypes.Type)
+	for i := 0; i < goTypeArgs.Len(); i++ {
+		typeArgs[goTypeParams.At(i)] = goTypeArgs.At(i)
+	}
+	// Mangle method name (e.g. "Stack[int].Push").
+	funcName := gen.methodName(fn)
+	return gen.newInstance(funcName, goFuncDecl, typeArgs), nil
+}
+
+// newInstance returns the LLVM IR function with the given name of the instance
+// of the generic Go function or method with the given type arguments, adding
+// the function to the module on first use.
+func (gen *Generator) newInstance(funcName string, goFuncDecl *ast.FuncDecl, typeArgs map[*gotypes.TypeParam]gotypes.Type) *ir.Function {
+	if f, ok := gen.funcs[funcName]; ok {
+		return f
+	}
+	// Lower function signature with type arguments substituted.
+	prevTypeArgs := gen.typeArgs
+	gen.typeArgs = typeArgs
+	// Receiver of method prepended as first parameter.
+	params := gen.irParams(goFuncDecl.Recv)
+	params = append(params, gen.irParams(goFuncDecl.Type.Params)...)
+	retType := gen.irRetType(goFuncDecl.Type.Results)
+	gen.typeArgs = prevTypeArgs
+	f := gen.m.NewFunc(funcName, retType, params...)
+	gen.funcs[funcName] = f
+	inst := &funcInstance{
+		goFuncDecl: goFuncDecl,
+		typeArgs:   typeArgs,
+		f:          f,
+	}
+	gen.instances = append(gen.instances, inst)
+	return f
+}
+
+// lowerInstances lowers the bodies of instantiated generic functions to LLVM
+// IR, emitting to m.
+func (gen *Generator) lowerInstances() {
+	// Lowering the body of an instance may instantiate further generic
+	// functions.
+	for len(gen.instances) > 0 {
+		inst := gen.instances[0]
+		gen.instances = gen.instances[1:]
+		gen.typeArgs = inst.typeArgs
+		gen.lowerFuncBody(inst.goFuncDecl, inst.f)
+		gen.typeArgs = nil
+	}
+}
+
+// subst returns the given Go type with type parameters substituted for the
+// type arguments of the generic function instance being lowered.
+//
+// Type parameters are substituted throughout composite types (e.g. `[]T`,
+// `map[K]V` or `Stack[T]`), so that distinct instances yield distinct types.
+// Interface types are returned unchanged, as support for type parameters in
+// method signatures of interface literals is not yet implemented.
+func (gen *Generator) subst(goType gotypes.Type) gotypes.Type {
+	if len(gen.typeArgs) == 0 {
+		return goType
+	}
+	switch goType := goType.(type) {
+	case *gotypes.TypeParam:
+		if goTypeArg, ok := gen.typeArgs[goType]; ok {
+			return goTypeArg
+		}
+	case *gotypes.Pointer:
+		if elem := gen.subst(goType.Elem()); elem != goType.Elem() {
+			return gotypes.NewPointer(elem)
+		}
+	case *gotypes.Slice:
+		if elem := gen.subst(goType.Elem()); elem != goType.Elem() {
+			return gotypes.NewSlice(elem)
+		}
+	case *gotypes.Array:
+		if elem := gen.subst(goType.Elem()); elem != goType.Elem() {
+			return gotypes.NewArray(elem, goType.Len())
+		}
+	case *gotypes.Chan:
+		if elem := gen.subst(goType.Elem()); elem != goType.Elem() {
+			return gotypes.NewChan(goType.Dir(), elem)
+		}
+	case *gotypes.Map:
+		key, elem := gen.subst(goType.Key()), gen.subst(goType.Elem())
+		if key != goType.Key() || elem != goType.Elem() {
+			return gotypes.NewMap(key, elem)
+		}
+	case *gotypes.Struct:
+		var fields []*gotypes.Var
+		var tags []string
+		substituted := false
+		for i := 0; i < goType.NumFields(); i++ {
+			field := goType.Field(i)
+			fieldType := gen.subst(field.Type())
+			if fieldType != field.Type() {
+				substituted = true
+				field = gotypes.NewField(field.Pos(), field.Pkg(), field.Name(), fieldType, field.Embedded())
+			}
+			fields = append(fields, field)
+			tags = append(tags, goType.Tag(i))
+		}
+		if substituted {
+			return gotypes.NewStruct(fields, tags)
+		}
+	case *gotypes.Tuple:
+		var vars []*gotypes.Var
+		substituted := false
+		for i := 0; i < goType.Len(); i++ {
+			v := goType.At(i)
+			typ := gen.subst(v.Type())
+			if typ != v.Type() {
+				substituted = true
+				v = gotypes.NewVar(v.Pos(), v.Pkg(), v.Name(), typ)
+			}
+			vars = append(vars, v)
+		}
+		if substituted {
+			return gotypes.NewTuple(vars...)
+		}
+	case *gotypes.Signature:
+		params := gen.subst(goType.Params()).(*gotypes.Tuple)
+		results := gen.subst(goType.Results()).(*gotypes.Tuple)
+		if params != goType.Params() || results != goType.Results() {
+			return gotypes.NewSignatureType(goType.Recv(), nil, nil, params, results, goType.Variadic())
+		}
+	case *gotypes.Named:
+		goTypeArgs := goType.TypeArgs()
+		var args []gotypes.Type
+		substituted := false
+		for i := 0; i < goTypeArgs.Len(); i++ {
+			arg := gen.subst(goTypeArgs.At(i))
+			if arg != goTypeArgs.At(i) {
+				substituted = true
+			}
+			args = append(args, arg)
+		}
+		if substituted {
+			// Instance of generic type with type arguments referring to type
+			// parameters (e.g. `Stack[T]`).
+			inst, err := gotypes.Instantiate(nil, goType.Origin(), args, false)
+			if err != nil {
+				// Unreachable, as the type arguments satisfy the constraints
+				// of the type-checked generic function instance.
+				panic(fmt.Errorf("unable to instantiate generic type %v; %v", goType, err))
+			}
+			return inst
+		}
+	}
+	return goType
+}
+
+// hasGenericRecv reports whether the given Go function declaration is a method
+// of a generic type (e.g. `func (s *Stack[T]) Push(v T)`).
+func hasGenericRecv(goFuncDecl *ast.FuncDecl) bool {
+	if goFuncDecl.Recv == nil || len(goFuncDecl.Recv.List) == 0 {
+		return false
+	}
+	goRecvType := goFuncDecl.Recv.List[0].Type
+	if goStarExpr, ok := goRecvType.(*ast.StarExpr); ok {
+		goRecvType = goStarExpr.X
+	}
+	switch unparen(goRecvType).(type) {
+	case *ast.IndexExpr, *ast.IndexListExpr:
+		return true
+	default:
+		return false
+	}
+}
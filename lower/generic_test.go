@@ -0,0 +1,76 @@
+package lower
+
+import "testing"
+
+func TestGenericFunc(t *testing.T) {
+	const src = `package p
+
+type Number interface {
+	~int | ~float64
+}
+
+func Max[T Number](a, b T) T {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func Id[T any](x T) T {
+	return x
+}
+
+func Wrap[T any](x []T) []T {
+	return Id(x)
+}
+
+func f() {
+	_ = Max(1, 2)
+	_ = Max(1.5, 2.5)
+	_ = Wrap([]int{1})
+	_ = Wrap([]float64{1})
+}
+`
+	module := lowerSource(t, src)
+	wantIR(t, mustFuncDef(t, module, "Max[int]"), "icmp sgt i64")
+	wantIR(t, mustFuncDef(t, module, "Max[float64]"), "fcmp ogt double")
+	// Type parameters are substituted within composite type arguments (e.g.
+	// `Id[[]T]` in the body of Wrap).
+	mustFuncDef(t, module, "Id[[]int]")
+	mustFuncDef(t, module, "Id[[]float64]")
+	rejectIR(t, module, "[]T")
+}
+
+func TestGenericType(t *testing.T) {
+	const src = `package p
+
+type Stack[T any] struct {
+	elems []T
+}
+
+func (s *Stack[T]) Push(v T) {
+	s.elems = append(s.elems, v)
+}
+
+func (s *Stack[T]) Len() int {
+	return len(s.elems)
+}
+
+func f() int {
+	var a Stack[int]
+	a.Push(1)
+	var b Stack[string]
+	b.Push("x")
+	return a.Len() + b.Len()
+}
+`
+	module := lowerSource(t, src)
+	wantIR(t, module, `%"Stack[int]" = type`, `%"Stack[string]" = type`)
+	// Methods are instantiated per type instance.
+	wantIR(t, mustFuncDef(t, module, "Stack[int].Push"), `%"Stack[int]"* %`)
+	wantIR(t, mustFuncDef(t, module, "Stack[string].Push"), `%"Stack[string]"* %`)
+	mustFuncDef(t, module, "Stack[int].Len")
+	mustFuncDef(t, module, "Stack[string].Len")
+	def := mustFuncDef(t, module, "f")
+	wantIR(t, def, `@"Stack[int].Push"(`, `@"Stack[string].Push"(`)
+}
@@ -2,6 +2,7 @@ package lower
 
 import (
 	"go/ast"
+	gotypes "go/types"
 
 	"github.com/llir/llvm/ir"
 )
@@ -13,7 +14,12 @@ func (gen *Generator) irParams(old *ast.FieldList) []*ir.Param {
 	}
 	var params []*ir.Param
 	for _, oldParam := range old.List {
-		typ, err := gen.irTypeOf(oldParam.Type)
+		goType := gen.pkg.TypesInfo.TypeOf(oldParam.Type)
+		if goEllipsis, ok := oldParam.Type.(*ast.Ellipsis); ok {
+			// Variadic parameter of slice type (e.g. `args ...interface{}`).
+			goType = gotypes.NewSlice(gen.pkg.TypesInfo.TypeOf(goEllipsis.Elt))
+		}
+		typ, err := gen.irType(goType)
 		if err != nil {
 			gen.eh(err)
 			continue
@@ -0,0 +1,129 @@
+package lower
+
+import (
+	"go/ast"
+	gotypes "go/types"
+
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/mewspring/toy/irgen"
+	"github.com/pkg/errors"
+)
+
+// box converts the concrete value x of the Go type from to the given interface
+// type, emitting to f. The LLVM IR type t is the type of the interface value.
+//
+// A copy of the concrete value is stored on the heap, and the interface value
+// holds a pointer to the copy together with the type descriptor of the dynamic
+// type (or the itable of the dynamic type for method interfaces).
+func (fgen *funcGen) box(x value.Value, from gotypes.Type, goIface *gotypes.Interface, t types.Type) (value.Value, error) {
+	// Untyped constants (e.g. `true` in `var v interface{} = true`) are boxed
+	// with their default type.
+	from = gotypes.Default(from)
+	mem := fgen.newObject(x.Type())
+	fgen.newStore(x, mem)
+	data := fgen.cur.NewBitCast(mem, types.NewPointer(types.I8))
+	if !goIface.Empty() {
+		// Method interfaces hold the itable of the dynamic type, through which
+		// methods are invoked.
+		tab, err := fgen.gen.itab(from, goIface)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return irgen.NewAggregate(fgen.cur, t, tab, data), nil
+	}
+	typ := fgen.gen.typeDesc(from)
+	return irgen.NewAggregate(fgen.cur, t, typ, data), nil
+}
+
+// convertIface converts the interface value x of the Go interface type from to
+// the interface type to, emitting to f. The LLVM IR type t is the type of the
+// resulting interface value.
+//
+// The data word is retained, while the first word is replaced by the itable of
+// the dynamic type for the target interface (or the type descriptor of the
+// dynamic type for empty target interfaces), as located by the runtime. The
+// runtime maps nil interface values to nil interface values.
+func (fgen *funcGen) convertIface(x value.Value, from, to gotypes.Type, t types.Type) (value.Value, error) {
+	goFromIface := from.Underlying().(*gotypes.Interface)
+	goToIface := to.Underlying().(*gotypes.Interface)
+	i8Ptr := types.NewPointer(types.I8)
+	itab := fgen.cur.NewExtractValue(x, 0)
+	data := fgen.cur.NewExtractValue(x, 1)
+	var tab value.Value
+	switch {
+	case goFromIface.Empty():
+		// Conversions from empty interfaces to method interfaces require type
+		// assertions (e.g. `v.(io.Writer)`).
+		return nil, errors.Errorf("invalid conversion from empty interface %v to method interface %v", from, to)
+	case goToIface.Empty():
+		// Type descriptor of dynamic type, as stored in the itable.
+		convI2E := fgen.gen.runtimeFunc("convI2E", i8Ptr, i8Ptr)
+		tab = fgen.cur.NewCall(convI2E, itab)
+	default:
+		// Itable of dynamic type for the target interface.
+		convI2I := fgen.gen.runtimeFunc("convI2I", i8Ptr, i8Ptr, i8Ptr)
+		tab = fgen.cur.NewCall(convI2I, fgen.gen.typeDesc(to), itab)
+	}
+	return irgen.NewAggregate(fgen.cur, t, tab, data), nil
+}
+
+// lowerIfaceEqual lowers the equality comparison of the Go interface operands
+// x and y of the binary expression to LLVM IR, emitting to f. A non-interface
+// operand compared to an interface operand is boxed to the interface type of
+// the other operand.
+func (fgen *funcGen) lowerIfaceEqual(goExpr *ast.BinaryExpr, x, y value.Value) (value.Value, error) {
+	if fgen.isNil(goExpr.X) || fgen.isNil(goExpr.Y) {
+		// Comparison against nil; an interface value is nil if it has no
+		// dynamic type.
+		xType := fgen.cur.NewExtractValue(x, 0)
+		yType := fgen.cur.NewExtractValue(y, 0)
+		return fgen.cur.NewICmp(enum.IPredEQ, xType, yType), nil
+	}
+	info := fgen.gen.pkg.TypesInfo
+	goXType, goYType := fgen.gen.subst(info.TypeOf(goExpr.X)), fgen.gen.subst(info.TypeOf(goExpr.Y))
+	var err error
+	switch {
+	case !gotypes.IsInterface(goXType):
+		// Box concrete operand (e.g. `1 == v` for v of type interface{}).
+		x, err = fgen.convert(x, goXType, goYType)
+	case !gotypes.IsInterface(goYType):
+		// Box concrete operand (e.g. `v == 1` for v of type interface{}).
+		y, err = fgen.convert(y, goYType, goXType)
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// Interface values are equal if they have identical dynamic types and equal
+	// dynamic values.
+	f := fgen.gen.runtimeFunc("ifaceeq", types.I1, x.Type(), y.Type())
+	return fgen.cur.NewCall(f, x, y), nil
+}
+
+// isIfaceOperand reports whether the operands of the given Go binary expression
+// are of interface type.
+func (fgen *funcGen) isIfaceOperand(goExpr *ast.BinaryExpr) bool {
+	info := fgen.gen.pkg.TypesInfo
+	return gotypes.IsInterface(info.TypeOf(goExpr.X)) || gotypes.IsInterface(info.TypeOf(goExpr.Y))
+}
+
+// typeDesc returns a pointer to the type descriptor of the given Go type,
+// adding the type descriptor to the module on first use.
+//
+// Type descriptors are uniquely identified by their address, and hold the name
+// of the type.
+func (gen *Generator) typeDesc(goType gotypes.Type) constant.Constant {
+	typeName := gotypes.TypeString(goType, nil)
+	name := "toy.type." + typeName
+	g, ok := gen.typeDescs[name]
+	if !ok {
+		g = gen.m.NewGlobalDef(name, constant.NewCharArrayFromString(typeName))
+		g.Immutable = true
+		// Type descriptors are merged across modules.
+		g.Linkage = enum.LinkageLinkOnceODR
+		gen.typeDescs[name] = g
+	}
+	return constant.NewBitCast(g, types.NewPointer(types.I8))
+}
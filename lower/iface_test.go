@@ -0,0 +1,111 @@
+package lower
+
+import "testing"
+
+func TestRecoverNil(t *testing.T) {
+	const src = `package p
+
+func f() bool {
+	r := recover()
+	return r != nil
+}
+`
+	def := mustFuncDef(t, lowerSource(t, src), "f")
+	// An interface value is nil if it has no dynamic type.
+	wantIR(t, def, "call %toy.eface @toy.recover()", "extractvalue %toy.eface", "icmp eq i8*")
+	rejectIR(t, def, "@toy.ifaceeq")
+}
+
+func TestIfaceEqualConcrete(t *testing.T) {
+	const src = `package p
+
+func f(v interface{}) bool {
+	return v == 1
+}
+
+func g(v interface{}) bool {
+	return 2 != v
+}
+`
+	module := lowerSource(t, src)
+	// The concrete operand is boxed before comparing the interface values.
+	for _, name := range []string{"f", "g"} {
+		def := mustFuncDef(t, module, name)
+		wantIR(t, def, "@toy.type.int", "call i1 @toy.ifaceeq(%toy.eface %")
+		rejectIR(t, def, "ifaceeq(%toy.eface %0, i64", "i64 1)", "i64 2)")
+	}
+}
+
+func TestBoxUntypedBool(t *testing.T) {
+	const src = `package p
+
+func f() interface{} {
+	var v interface{} = true
+	return v
+}
+`
+	module := lowerSource(t, src)
+	def := mustFuncDef(t, module, "f")
+	// Untyped constants are boxed with their default type.
+	wantIR(t, def, "@toy.type.bool", "store i1 true")
+	rejectIR(t, module, "untyped")
+}
+
+func TestIfaceToIface(t *testing.T) {
+	const src = `package p
+
+type Reader interface {
+	Read() int
+}
+
+type ReadWriter interface {
+	Read() int
+	Write(x int)
+}
+
+func narrow(rw ReadWriter) Reader {
+	return rw
+}
+
+func erase(rw ReadWriter) interface{} {
+	return rw
+}
+`
+	module := lowerSource(t, src)
+	// The itable of the dynamic type for the target interface is located by the
+	// runtime, and the data word retained.
+	narrow := mustFuncDef(t, module, "narrow")
+	wantIR(t, narrow, "call i8* @toy.convI2I(i8* ", "toy.type.example.com/test.Reader")
+	wantCount(t, narrow, "extractvalue %toy.iface", 2)
+	wantIR(t, mustFuncDef(t, module, "erase"), "call i8* @toy.convI2E(i8* ")
+}
+
+func TestStringerCall(t *testing.T) {
+	const src = `package p
+
+type Stringer interface {
+	String() string
+}
+
+type Name string
+
+func (n Name) String() string {
+	return string(n)
+}
+
+func f(n Name) string {
+	var s Stringer = n
+	return s.String()
+}
+`
+	module := lowerSource(t, src)
+	// The value is boxed with the itable of Name for Stringer, and the method
+	// invoked through the itable.
+	def := mustFuncDef(t, module, "f")
+	wantIR(t, def, "toy.itab.Name,interface{String() string}", "extractvalue %toy.iface", "getelementptr")
+	rejectIR(t, def, "@Name.String(")
+	// The itable refers to the wrapper of the method, which loads the receiver
+	// from the data word.
+	wrapper := mustFuncDef(t, module, "Name.String$iface")
+	wantIR(t, wrapper, "call { i8*, i64 } @Name.String(")
+}
@@ -3,6 +3,9 @@ package lower
 import (
 	"fmt"
 	"go/ast"
+	"go/token"
+	gotypes "go/types"
+	"strings"
 
 	"github.com/llir/llvm/ir/types"
 )
@@ -11,6 +14,11 @@ import (
 // definitions, global variable and function declarations and definitions
 // (without bodies but with types) of the Go package.
 func (gen *Generator) indexPackage() {
+	// Index directives before lowering any types, as types may be referred to
+	// before their declaration.
+	for _, file := range gen.pkg.Syntax {
+		gen.indexDirectives(file)
+	}
 	for _, file := range gen.pkg.Syntax {
 		gen.indexFile(file)
 	}
@@ -26,6 +34,58 @@ func (gen *Generator) indexFile(file *ast.File) {
 	}
 }
 
+// packedDirective marks struct type definitions to be lowered to packed LLVM IR
+// struct types, without padding between fields (e.g. to match the layout of
+// packed C structs).
+//
+//	//toy:packed
+//	type Header struct {
+//		Tag  uint8
+//		Size uint32
+//	}
+const packedDirective = "//toy:packed"
+
+// indexDirectives indexes the type definitions of the Go source file marked by
+// directives.
+func (gen *Generator) indexDirectives(file *ast.File) {
+	for _, goDecl := range file.Decls {
+		goGenDecl, ok := goDecl.(*ast.GenDecl)
+		if !ok || goGenDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, goSpec := range goGenDecl.Specs {
+			goTypeSpec := goSpec.(*ast.TypeSpec)
+			doc := goTypeSpec.Doc
+			if doc == nil && !goGenDecl.Lparen.IsValid() {
+				// Ungrouped type declaration (e.g. `type T struct{}`).
+				doc = goGenDecl.Doc
+			}
+			if !hasDirective(doc, packedDirective) {
+				continue
+			}
+			obj := gen.pkg.TypesInfo.Defs[goTypeSpec.Name].(*gotypes.TypeName)
+			if _, ok := obj.Type().Underlying().(*gotypes.Struct); !ok {
+				gen.Errorf("invalid use of %s directive on type %q; expected struct type, got %v", packedDirective, obj.Name(), obj.Type().Underlying())
+				continue
+			}
+			gen.packed[obj] = true
+		}
+	}
+}
+
+// hasDirective reports whether the given comment group contains the directive.
+func hasDirective(doc *ast.CommentGroup, directive string) bool {
+	if doc == nil {
+		return false
+	}
+	for _, comment := range doc.List {
+		if strings.TrimSpace(comment.Text) == directive {
+			return true
+		}
+	}
+	return false
+}
+
 // === [ Declarations ] ========================================================
 
 // indexDecl indexes the global identifier and creates a scaffolding IR type
@@ -48,49 +108,78 @@ func (gen *Generator) indexDecl(goDecl ast.Decl) {
 // function declaration or definition (without bodies but with types) of the Go
 // function declaration.
 func (gen *Generator) indexFuncDecl(goFuncDecl *ast.FuncDecl) {
+	if goFuncDecl.Type.TypeParams != nil {
+		// Generic function; indexed on instantiation.
+		obj := gen.pkg.TypesInfo.Defs[goFuncDecl.Name].(*gotypes.Func)
+		gen.genericFuncs[obj] = goFuncDecl
+		return
+	}
+	if hasGenericRecv(goFuncDecl) {
+		// Method of generic type; indexed on instantiation.
+		obj := gen.pkg.TypesInfo.Defs[goFuncDecl.Name].(*gotypes.Func)
+		gen.genericFuncs[obj] = goFuncDecl
+		return
+	}
+	if isInitFunc(goFuncDecl) {
+		gen.indexInitFunc(goFuncDecl)
+		return
+	}
 	// Receiver.
 	receivers := gen.irParams(goFuncDecl.Recv)
 	// Function parameters.
 	params := gen.irParams(goFuncDecl.Type.Params)
 	// Add reciver to function parameters if present.
-	funcName := goFuncDecl.Name.String()
+	funcName := gen.funcDeclName(goFuncDecl)
 	switch len(receivers) {
 	case 0:
 		// nothing to do.
 	case 1:
-		// To avoid function name collisions, rename "M" to "T.M".
-		recvType := receivers[0].Typ
-		funcName = fmt.Sprintf("%s.%s", recvType.Name(), funcName)
 		// Prepend receiver as first parameter of function.
 		params = append(receivers, params...)
 	default:
 		panic(fmt.Errorf("support for multiple receivers not yet implemented; %q has %d receivers", funcName, len(receivers)))
 	}
 	// Return type.
-	results := gen.irParams(goFuncDecl.Type.Results)
-	var retType types.Type
+	retType := gen.irRetType(goFuncDecl.Type.Results)
+	// Add function.
+	f := gen.m.NewFunc(funcName, retType, params...)
+	if prev, ok := gen.funcs[funcName]; ok {
+		gen.Errorf("function %q already present; prev `%v`, new `%v`", funcName, prev, f)
+		return
+	}
+	gen.funcs[funcName] = f
+}
+
+// funcDeclName returns the LLVM IR function name of the Go function
+// declaration. To avoid function name collisions, methods "M" are named "T.M"
+// after their receiver base type T.
+func (gen *Generator) funcDeclName(goFuncDecl *ast.FuncDecl) string {
+	if goFuncDecl.Recv == nil {
+		return goFuncDecl.Name.String()
+	}
+	fn := gen.pkg.TypesInfo.Defs[goFuncDecl.Name].(*gotypes.Func)
+	return gen.methodName(fn)
+}
+
+// irRetType returns the LLVM IR return type based on the given Go result
+// parameters.
+func (gen *Generator) irRetType(goResults *ast.FieldList) types.Type {
+	results := gen.irParams(goResults)
 	switch len(results) {
 	case 0:
 		// void return.
-		retType = types.Void
+		return types.Void
 	case 1:
 		// single value return.
-		retType = results[0].Typ
+		return results[0].Typ
 	default:
 		// multiple value return.
 		var resultTypes []types.Type
 		for _, result := range results {
 			resultTypes = append(resultTypes, result.Typ)
 		}
-		retType = types.NewStruct(resultTypes...)
-	}
-	// Add function.
-	f := gen.m.NewFunc(funcName, retType, params...)
-	if prev, ok := gen.funcs[funcName]; ok {
-		gen.Errorf("function %q already present; prev `%v`, new `%v`", funcName, prev, f)
-		return
+		return types.NewStruct(resultTypes...)
 	}
-	gen.funcs[funcName] = f
 }
 
 // --- [ Generic declarations ] ------------------------------------------------
@@ -99,6 +188,10 @@ func (gen *Generator) indexFuncDecl(goFuncDecl *ast.FuncDecl) {
 // definition, or global variable declaration or definition (without bodies but
 // with types) of the Go generic declaration.
 func (gen *Generator) indexGenDecl(goGenDecl *ast.GenDecl) {
+	if goGenDecl.Tok == token.CONST {
+		// Constants are folded at their use sites.
+		return
+	}
 	for _, goSpec := range goGenDecl.Specs {
 		gen.indexSpec(goSpec)
 	}
@@ -125,8 +218,11 @@ func (gen *Generator) indexSpec(goSpec ast.Spec) {
 func (gen *Generator) indexValueSpec(goSpec *ast.ValueSpec) {
 	for _, goName := range goSpec.Names {
 		name := goName.String()
-		// Global variable declaration or definition.
-		typ, err := gen.irTypeOf(goSpec.Type)
+		// Global variable declaration or definition. The type is determined by
+		// the type-checker, as the type may be omitted from the specifier (e.g.
+		// `var x = 1 << N`).
+		goType := gen.pkg.TypesInfo.Defs[goName].Type()
+		typ, err := gen.irType(goType)
 		if err != nil {
 			gen.eh(err)
 			continue
@@ -0,0 +1,113 @@
+package lower
+
+import (
+	"fmt"
+	"go/ast"
+	"sort"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+)
+
+// indexInitFunc creates a scaffolding IR function definition (without body) of
+// the Go init function declaration. A package may declare any number of init
+// functions, which are named by order of declaration (e.g. "init.0"). Init
+// functions are only invoked by the package initializer, and have internal
+// linkage to prevent name collisions between packages.
+func (gen *Generator) indexInitFunc(goFuncDecl *ast.FuncDecl) {
+	funcName := fmt.Sprintf("init.%d", len(gen.inits))
+	f := gen.m.NewFunc(funcName, types.Void)
+	f.Linkage = enum.LinkageInternal
+	gen.initFuncs[goFuncDecl] = f
+	gen.inits = append(gen.inits, f)
+}
+
+// lowerPackageInit lowers the package initializer of the Go package to LLVM IR,
+// emitting to m.
+//
+// The package initializer first initializes the imported packages, including
+// packages imported for their side effects only (e.g. `import _ "pkg"`), and
+// then invokes the init functions of the package in order of declaration. Each
+// package is initialized at most once, even when imported by several packages.
+//
+//	if initdone {
+//	   return
+//	}
+//	initdone = true
+//	imp.init() // for each imported package with a package initializer
+//	init.0()   // for each init function
+//
+// The package initializer of the main package is invoked on entry to the main
+// function.
+func (gen *Generator) lowerPackageInit() {
+	done := gen.m.NewGlobalDef(pkgInitName(gen.pkg.PkgPath)+"done", constant.False)
+	done.Linkage = enum.LinkageInternal
+	f := gen.m.NewFunc(pkgInitName(gen.pkg.PkgPath), types.Void)
+	fgen := gen.newFuncGen()
+	fgen.f = f
+	entry := f.NewBlock("")
+	initBlock := f.NewBlock("")
+	doneBlock := f.NewBlock("")
+	fgen.cur = entry
+	isDone := fgen.newLoad(done)
+	entry.NewCondBr(isDone, doneBlock, initBlock)
+	fgen.cur = initBlock
+	fgen.newStore(constant.True, done)
+	// Initialize imported packages in order of import path, for reproducible
+	// output.
+	var importPaths []string
+	for importPath, imp := range gen.pkg.Imports {
+		if !hasPkgInit(imp.PkgPath) {
+			continue
+		}
+		importPaths = append(importPaths, importPath)
+	}
+	sort.Strings(importPaths)
+	for _, importPath := range importPaths {
+		imp := gen.pkg.Imports[importPath]
+		impInit := gen.m.NewFunc(pkgInitName(imp.PkgPath), types.Void)
+		initBlock.NewCall(impInit)
+	}
+	for _, init := range gen.inits {
+		initBlock.NewCall(init)
+	}
+	initBlock.NewRet(nil)
+	doneBlock.NewRet(nil)
+	if main, ok := gen.funcs["main"]; ok && gen.pkg.Name == "main" && len(main.Blocks) > 0 {
+		// Initialize the main package (and transitively its imports) before
+		// running the main function.
+		entry := main.Blocks[0]
+		call := ir.NewCall(f)
+		call.Parent = entry
+		entry.Insts = append([]ir.Instruction{call}, entry.Insts...)
+	}
+}
+
+// ### [ Helper functions ] ####################################################
+
+// isInitFunc reports whether the given Go function declaration is an init
+// function of the package.
+func isInitFunc(goFuncDecl *ast.FuncDecl) bool {
+	return goFuncDecl.Recv == nil && goFuncDecl.Name.Name == "init"
+}
+
+// hasPkgInit reports whether the Go package with the given import path has a
+// package initializer. Package unsafe is provided by the compiler, and the
+// functions of package sync/atomic are lowered to atomic instructions; thus
+// neither package is compiled.
+func hasPkgInit(pkgPath string) bool {
+	switch pkgPath {
+	case "unsafe", "sync/atomic":
+		return false
+	default:
+		return true
+	}
+}
+
+// pkgInitName returns the name of the package initializer of the Go package
+// with the given import path (e.g. "example.com/foo.init").
+func pkgInitName(pkgPath string) string {
+	return pkgPath + ".init"
+}
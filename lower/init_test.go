@@ -0,0 +1,53 @@
+package lower
+
+import "testing"
+
+func TestBlankImportInit(t *testing.T) {
+	files := map[string]string{
+		"main.go": `package main
+
+import _ "example.com/test/foo"
+
+func init() {}
+
+func main() {}
+`,
+		"foo/foo.go": `package foo
+
+func init() {}
+`,
+	}
+	module := lowerFiles(t, files)
+	// The package initializer is invoked on entry to the main function.
+	main := mustFuncDef(t, module, "main")
+	wantIR(t, main, `call void @"example.com/test.init"()`)
+	// Packages imported for their side effects are initialized by the package
+	// initializer of the importing package.
+	init := mustFuncDef(t, module, "example.com/test.init")
+	wantIR(t, init, `call void @"example.com/test/foo.init"()`, `call void @init.0()`)
+	wantIR(t, funcDecl(module, "example.com/test/foo.init"), "declare void")
+	// Init functions and the initialization flag are local to the package.
+	wantIR(t, module, "define internal void @init.0()", `@"example.com/test.initdone" = internal global i1 false`)
+}
+
+func TestUncompiledImportInit(t *testing.T) {
+	const src = `package p
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+var x int64
+
+func f() uintptr {
+	atomic.AddInt64(&x, 1)
+	return unsafe.Sizeof(x)
+}
+`
+	module := lowerSource(t, src)
+	// Package unsafe and sync/atomic are not compiled, and thus have no package
+	// initializer.
+	rejectIR(t, module, `@"unsafe.init"`, `@"sync/atomic.init"`)
+	wantIR(t, mustFuncDef(t, module, "example.com/test.init"), "ret void")
+}
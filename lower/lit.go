@@ -0,0 +1,175 @@
+package lower
+
+import (
+	"go/ast"
+	goconstant "go/constant"
+	gotypes "go/types"
+
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/mewspring/toy/irgen"
+	"github.com/pkg/errors"
+)
+
+// lowerStructLit lowers the Go struct composite literal (e.g. `T{a, b}` or
+// `T{X: a}`) to LLVM IR, emitting to f.
+//
+// The literal is constructed in a zero-initialized temporary of the struct
+// type, into which each element is stored, after which the struct value is
+// loaded from the temporary.
+func (fgen *funcGen) lowerStructLit(goLit *ast.CompositeLit, goType gotypes.Type) (value.Value, error) {
+	goStructType := goType.Underlying().(*gotypes.Struct)
+	t, err := fgen.gen.irType(goType)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	mem := fgen.newLocal(t)
+	// Fields without elements hold the zero value of their type.
+	fgen.newStore(zeroValue(t), mem)
+	zero := constant.NewInt(types.I32, 0)
+	for i, goElt := range goLit.Elts {
+		// Field index of element; keyed elements specify the field name, and
+		// positional elements are in field order.
+		index := i
+		if goKeyValue, ok := goElt.(*ast.KeyValueExpr); ok {
+			goKey, ok := goKeyValue.Key.(*ast.Ident)
+			if !ok {
+				return nil, errors.Errorf("invalid struct literal key; expected field name, got %T", goKeyValue.Key)
+			}
+			index, ok = fieldIndex(goStructType, goKey.Name)
+			if !ok {
+				return nil, errors.Errorf("unknown field %q in struct literal of type %v", goKey.Name, goType)
+			}
+			goElt = goKeyValue.Value
+		}
+		v, err := fgen.lowerArg(goElt, goStructType.Field(index).Type())
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		field := fgen.cur.NewGetElementPtr(mem, zero, constant.NewInt(types.I32, int64(index)))
+		fgen.newStore(v, field)
+	}
+	return fgen.newLoad(mem), nil
+}
+
+// fieldIndex returns the index of the field with the given name in the Go
+// struct type. The IR struct type has the same field order as the Go struct
+// type. The boolean return value indicates success.
+func fieldIndex(goStructType *gotypes.Struct, name string) (int, bool) {
+	for i := 0; i < goStructType.NumFields(); i++ {
+		if goStructType.Field(i).Name() == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// lowerArrayLit lowers the Go array composite literal (e.g. `[3]int{1, 2, 3}`
+// or `[5]int{2: 7}`) to LLVM IR, emitting to f.
+//
+// The literal is constructed in a zero-initialized temporary of the array type,
+// into which each element is stored, after which the array value is loaded from
+// the temporary.
+func (fgen *funcGen) lowerArrayLit(goLit *ast.CompositeLit, goType gotypes.Type) (value.Value, error) {
+	goArrayType := goType.Underlying().(*gotypes.Array)
+	t, err := fgen.gen.irType(goType)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	mem := fgen.newLocal(t)
+	if err := fgen.storeElems(goLit, mem, t, goArrayType.Elem()); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return fgen.newLoad(mem), nil
+}
+
+// lowerSliceLit lowers the Go slice composite literal (e.g. `[]int{1, 2, 3}`)
+// to LLVM IR, emitting to f.
+//
+// The elements are stored in a zero-initialized backing array allocated on the
+// heap, with length and capacity of the highest element index plus one.
+func (fgen *funcGen) lowerSliceLit(goLit *ast.CompositeLit, goType gotypes.Type) (value.Value, error) {
+	goSliceType := goType.Underlying().(*gotypes.Slice)
+	t, err := fgen.gen.irType(goType)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	elemType, err := fgen.gen.irType(goSliceType.Elem())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	n, err := fgen.litLen(goLit)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	arrayType := types.NewArray(uint64(n), elemType)
+	array := fgen.newObject(arrayType)
+	if err := fgen.storeElems(goLit, array, arrayType, goSliceType.Elem()); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	zero := constant.NewInt(types.I64, 0)
+	data := fgen.cur.NewGetElementPtr(array, zero, zero)
+	length := constant.NewInt(types.I64, n)
+	return irgen.NewAggregate(fgen.cur, t, data, length, length), nil
+}
+
+// storeElems stores the elements of the Go array or slice composite literal to
+// the array memory of the given LLVM IR array type, emitting to f. Elements
+// without values hold the zero value of the Go element type.
+func (fgen *funcGen) storeElems(goLit *ast.CompositeLit, array value.Value, arrayType types.Type, goElemType gotypes.Type) error {
+	fgen.newStore(zeroValue(arrayType), array)
+	zero := constant.NewInt(types.I64, 0)
+	err := fgen.forEachElem(goLit, func(index int64, goElt ast.Expr) error {
+		v, err := fgen.lowerArg(goElt, goElemType)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		elem := fgen.cur.NewGetElementPtr(array, zero, constant.NewInt(types.I64, index))
+		fgen.newStore(v, elem)
+		return nil
+	})
+	return errors.WithStack(err)
+}
+
+// litLen returns the length of the Go array or slice composite literal; i.e.
+// the highest element index plus one.
+func (fgen *funcGen) litLen(goLit *ast.CompositeLit) (int64, error) {
+	var n int64
+	err := fgen.forEachElem(goLit, func(index int64, goElt ast.Expr) error {
+		if index+1 > n {
+			n = index + 1
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return n, nil
+}
+
+// forEachElem invokes f for each element of the Go array or slice composite
+// literal, with the index and value of the element. Keyed elements (e.g. `2: 7`)
+// specify the index, and positional elements follow the previous element.
+func (fgen *funcGen) forEachElem(goLit *ast.CompositeLit, f func(index int64, goElt ast.Expr) error) error {
+	var index int64
+	for _, goElt := range goLit.Elts {
+		if goKeyValue, ok := goElt.(*ast.KeyValueExpr); ok {
+			tv := fgen.gen.pkg.TypesInfo.Types[goKeyValue.Key]
+			if tv.Value == nil {
+				return errors.Errorf("invalid array literal index; expected constant, got %T", goKeyValue.Key)
+			}
+			i, ok := goconstant.Int64Val(goconstant.ToInt(tv.Value))
+			if !ok {
+				return errors.Errorf("invalid array literal index %v", tv.Value)
+			}
+			index = i
+			goElt = goKeyValue.Value
+		}
+		if err := f(index, goElt); err != nil {
+			return errors.WithStack(err)
+		}
+		index++
+	}
+	return nil
+}
@@ -4,17 +4,28 @@ package lower
 import (
 	"fmt"
 	"go/ast"
+	"go/token"
+	gotypes "go/types"
 
 	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/types"
 	"github.com/rickypai/natsort"
 )
 
 // Lower lowers the source code of the Go package to LLVM IR.
 func (gen *Generator) Lower() *ir.Module {
+	// Record the primary source file of the Go package.
+	if len(gen.pkg.GoFiles) > 0 {
+		gen.m.SourceFilename = gen.pkg.GoFiles[0]
+	}
 	// Index top-level declarations.
 	gen.indexPackage()
 	// Lower Go package to LLVM IR.
 	gen.lowerPackage()
+	// Lower instances of generic functions.
+	gen.lowerInstances()
+	// Lower package initializer.
+	gen.lowerPackageInit()
 	// Append type definitions to module.
 	var typeNames []string
 	for typeName := range gen.typeDefs {
@@ -65,27 +76,135 @@ func (gen *Generator) lowerFuncDecl(goFuncDecl *ast.FuncDecl) {
 		// Function declaration.
 		return
 	}
+	if goFuncDecl.Type.TypeParams != nil {
+		// Generic function; lowered on instantiation.
+		return
+	}
+	if hasGenericRecv(goFuncDecl) {
+		// Method of generic type; lowered on instantiation.
+		return
+	}
+	if isInitFunc(goFuncDecl) {
+		gen.lowerFuncBody(goFuncDecl, gen.initFuncs[goFuncDecl])
+		return
+	}
 	// Locate function definition.
-	funcName := goFuncDecl.Name.String()
+	funcName := gen.funcDeclName(goFuncDecl)
 	f, ok := gen.funcs[funcName]
 	if !ok {
 		gen.Errorf("unable to locate function definition %q", funcName)
 		return
 	}
+	gen.lowerFuncBody(goFuncDecl, f)
+}
+
+// lowerFuncBody lowers the body of the Go function declaration to LLVM IR,
+// emitting to f.
+func (gen *Generator) lowerFuncBody(goFuncDecl *ast.FuncDecl, f *ir.Function) {
 	// Create LLVM IR function generator.
 	fgen := gen.newFuncGen()
 	fgen.f = f
 	// Function scope.
 	fgen.scope = gen.scope.Innermost(goFuncDecl.Name.Pos())
+	// Function signature.
+	fgen.sig = gen.pkg.TypesInfo.Defs[goFuncDecl.Name].Type().(*gotypes.Signature)
+	fgen.lowerBody(goFuncDecl.Recv, goFuncDecl.Type, goFuncDecl.Body)
+}
+
+// lowerBody lowers the Go function body with the given receiver and function
+// type (holding the parameters) to LLVM IR, emitting to f.
+func (fgen *funcGen) lowerBody(goRecv *ast.FieldList, goFuncType *ast.FuncType, goBody *ast.BlockStmt) {
 	// Lower function body.
 	fgen.cur = fgen.f.NewBlock("entry")
-	fgen.lowerStmt(goFuncDecl.Body)
+	fgen.indexCaptured(goBody)
+	fgen.lowerCaptures()
+	fgen.lowerFuncParams(goRecv, goFuncType)
+	fgen.indexLabels(goBody)
+	fgen.lowerStmt(goBody)
+	// Add implicit return at the end of the function body if not already
+	// terminated.
+	if fgen.cur.Term == nil {
+		switch {
+		case fgen.cur != fgen.f.Blocks[0] && !hasPreds(fgen.f, fgen.cur):
+			// The end of the function body is unreachable (e.g. follow basic
+			// block of an infinite loop `for {}` without break statements).
+			fgen.cur.NewUnreachable()
+		case types.Equal(fgen.f.Sig.RetType, types.Void):
+			fgen.cur.NewRet(nil)
+		default:
+			// Functions with result parameters must end in a terminating
+			// statement, as verified by the type-checker; thus the end of the
+			// function body is unreachable.
+			fgen.cur.NewUnreachable()
+		}
+	}
+}
+
+// hasPreds reports whether the given basic block has any predecessors within
+// the function.
+func hasPreds(f *ir.Function, block *ir.BasicBlock) bool {
+	for _, pred := range f.Blocks {
+		if pred.Term == nil {
+			continue
+		}
+		for _, succ := range pred.Term.Succs() {
+			if succ == block {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lowerFuncParams allocates stack memory for the parameters (including the
+// receiver) of the Go function and stores the incoming arguments, so that
+// parameters may be used as local variables.
+func (fgen *funcGen) lowerFuncParams(goRecv *ast.FieldList, goFuncType *ast.FuncType) {
+	// Parameter names in the same order as the LLVM IR parameters (receiver
+	// first); nil for unnamed parameters.
+	var goNames []*ast.Ident
+	for _, goFields := range []*ast.FieldList{goRecv, goFuncType.Params} {
+		if goFields == nil {
+			continue
+		}
+		for _, goField := range goFields.List {
+			if len(goField.Names) == 0 {
+				// Unnamed parameter.
+				goNames = append(goNames, nil)
+				continue
+			}
+			goNames = append(goNames, goField.Names...)
+		}
+	}
+	params := fgen.f.Params
+	if fgen.ctx != nil {
+		// Skip hidden closure object parameter.
+		params = params[1:]
+	}
+	if len(goNames) != len(params) {
+		fgen.gen.Errorf("parameter count mismatch of function %q; expected %d, got %d", fgen.f.Name(), len(goNames), len(params))
+		return
+	}
+	for i, goName := range goNames {
+		if goName == nil || goName.Name == "_" {
+			// Parameter not accessible from function body.
+			continue
+		}
+		param := params[i]
+		obj := fgen.gen.pkg.TypesInfo.Defs[goName]
+		mem := fgen.newVar(obj, param.Type())
+		fgen.newStore(param, mem)
+	}
 }
 
 // --- [ Generic declarations ] ------------------------------------------------
 
 // lowerGenDecl lowers the Go generic declaration to LLVM IR.
 func (gen *Generator) lowerGenDecl(goGenDecl *ast.GenDecl) {
+	if goGenDecl.Tok == token.CONST {
+		// Constants are folded at their use sites.
+		return
+	}
 	for _, goSpec := range goGenDecl.Specs {
 		gen.lowerSpec(goSpec)
 	}
@@ -107,14 +226,17 @@ func (gen *Generator) lowerSpec(goSpec ast.Spec) {
 
 // lowerTypeSpec lowers the Go type specifier to LLVM IR, emitting to m.
 func (gen *Generator) lowerTypeSpec(goSpec *ast.TypeSpec) {
-	typ, err := gen.irTypeOf(goSpec.Type)
-	if err != nil {
+	if goSpec.TypeParams != nil {
+		// Generic type; lowered on instantiation.
+		return
+	}
+	// Type definitions are added to the module on first use by irType. Lower the
+	// type here to include type definitions not referred to by any value.
+	goType := gen.pkg.TypesInfo.Defs[goSpec.Name].Type()
+	if _, err := gen.irType(goType); err != nil {
 		gen.eh(err)
 		return
 	}
-	name := goSpec.Name.String()
-	typ.SetName(name)
-	gen.typeDefs[name] = typ
 }
 
 // lowerValueSpec lowers the Go value specifier to LLVM IR, emitting to m.
@@ -132,7 +254,8 @@ func (gen *Generator) lowerValueSpec(goSpec *ast.ValueSpec) {
 			return
 		}
 		goExpr := goSpec.Values[i]
-		init, err := gen.lowerGlobalInitExpr(goExpr)
+		goType := gen.pkg.TypesInfo.Defs[goName].Type()
+		init, err := gen.lowerGlobalInitExpr(goExpr, goType)
 		if err != nil {
 			gen.eh(err)
 			continue
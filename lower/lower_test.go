@@ -0,0 +1,221 @@
+package lower
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// modulePath is the module path of Go source code lowered by tests.
+const modulePath = "example.com/test"
+
+// lowerSource lowers the Go source code of a single file package to LLVM IR
+// assembly. The test fails if any error is reported during lowering.
+func lowerSource(t *testing.T, src string) string {
+	t.Helper()
+	return lowerFiles(t, map[string]string{"p.go": src})
+}
+
+// lowerFiles lowers the root package of a Go module with the given source files
+// (mapping from slash-separated file path relative to the module root to file
+// contents) to LLVM IR assembly. The test fails if any error is reported during
+// lowering.
+func lowerFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	module, errs := lowerModule(t, files, nil)
+	for _, err := range errs {
+		t.Errorf("unexpected error during lowering; %+v", err)
+	}
+	if len(errs) > 0 {
+		t.FailNow()
+	}
+	return module
+}
+
+// lowerErrors lowers the Go source code of a single file package to LLVM IR,
+// and returns the errors reported during lowering.
+func lowerErrors(t *testing.T, src string) []error {
+	t.Helper()
+	_, errs := lowerModule(t, map[string]string{"p.go": src}, nil)
+	return errs
+}
+
+// lowerModule lowers the root package of a Go module with the given source
+// files to LLVM IR assembly, and returns the errors reported during lowering.
+// The generator is configured by config if non-nil.
+func lowerModule(t *testing.T, files map[string]string, config func(gen *Generator)) (string, []error) {
+	t.Helper()
+	pkg := loadPackage(t, files, true)
+	return lowerPackage(pkg, config)
+}
+
+// lowerInvalid lowers the Go source code of a single file package which fails
+// to type-check (e.g. to exercise errors reported by the generator for invalid
+// operations), and returns the errors reported during lowering.
+func lowerInvalid(t *testing.T, src string) []error {
+	t.Helper()
+	pkg := loadPackage(t, map[string]string{"p.go": src}, false)
+	_, errs := lowerPackage(pkg, nil)
+	return errs
+}
+
+// lowerPackage lowers the given Go package to LLVM IR assembly, and returns the
+// errors reported during lowering. The generator is configured by config if
+// non-nil.
+func lowerPackage(pkg *packages.Package, config func(gen *Generator)) (string, []error) {
+	var errs []error
+	eh := func(err error) {
+		errs = append(errs, err)
+	}
+	gen := NewGenerator(eh, pkg)
+	if config != nil {
+		config(gen)
+	}
+	return gen.Lower().String(), errs
+}
+
+// loadPackage loads the root package of a Go module with the given source files
+// (mapping from slash-separated file path relative to the module root to file
+// contents). If strict is set, the test fails if the package fails to
+// type-check.
+func loadPackage(t *testing.T, files map[string]string, strict bool) *packages.Package {
+	t.Helper()
+	dir := t.TempDir()
+	goMod := "module " + modulePath + "\n\ngo 1.21\n"
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0644); err != nil {
+		t.Fatalf("unable to create go.mod; %v", err)
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("unable to create directory of %q; %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatalf("unable to create %q; %v", name, err)
+		}
+	}
+	cfg := &packages.Config{Mode: packages.LoadAllSyntax, Dir: dir}
+	pkgs, err := packages.Load(cfg, ".")
+	if err != nil {
+		t.Fatalf("unable to load packages; %v", err)
+	}
+	if len(pkgs) != 1 {
+		t.Fatalf("invalid number of packages; expected 1, got %d", len(pkgs))
+	}
+	if strict && packages.PrintErrors(pkgs) > 0 {
+		t.Fatalf("invalid Go source code")
+	}
+	return pkgs[0]
+}
+
+// wantCount reports an error unless the given substring occurs n times in the
+// LLVM IR assembly.
+func wantCount(t *testing.T, got, s string, n int) {
+	t.Helper()
+	if count := strings.Count(got, s); count != n {
+		t.Errorf("occurrences of %q mismatch; expected %d, got %d in:\n%s", s, n, count, got)
+	}
+}
+
+// wantError reports an error unless the first of the given errors reported
+// during lowering contains the given substring.
+func wantError(t *testing.T, errs []error, want string) {
+	t.Helper()
+	if len(errs) == 0 {
+		t.Errorf("expected error containing %q; got no error", want)
+		return
+	}
+	if got := errs[0].Error(); !strings.Contains(got, want) {
+		t.Errorf("error mismatch; expected %q in %q", want, got)
+	}
+}
+
+// funcDef returns the definition of the function with the given name in the
+// LLVM IR assembly, or the empty string if not present.
+func funcDef(module, name string) string {
+	for _, def := range strings.Split(module, "\ndefine ")[1:] {
+		header := def
+		if end := strings.Index(def, "\n"); end != -1 {
+			header = def[:end]
+		}
+		if !strings.Contains(header, "@"+name+"(") && !strings.Contains(header, `@"`+name+`"(`) {
+			continue
+		}
+		if end := strings.Index(def, "\n}"); end != -1 {
+			def = def[:end+len("\n}")]
+		}
+		return "define " + def
+	}
+	return ""
+}
+
+// funcDecl returns the declaration of the function with the given name in the
+// LLVM IR assembly, or the empty string if not present.
+func funcDecl(module, name string) string {
+	for _, line := range strings.Split(module, "\n") {
+		if !strings.HasPrefix(line, "declare ") {
+			continue
+		}
+		if strings.Contains(line, "@"+name+"(") || strings.Contains(line, `@"`+name+`"(`) {
+			return line
+		}
+	}
+	return ""
+}
+
+// mustFuncDef returns the definition of the function with the given name in the
+// LLVM IR assembly. The test fails if not present.
+func mustFuncDef(t *testing.T, module, name string) string {
+	t.Helper()
+	def := funcDef(module, name)
+	if len(def) == 0 {
+		t.Fatalf("unable to locate definition of function %q in:\n%s", name, module)
+	}
+	return def
+}
+
+// wantIR reports an error for each of the given substrings not present in the
+// LLVM IR assembly.
+func wantIR(t *testing.T, got string, want ...string) {
+	t.Helper()
+	for _, s := range want {
+		if !strings.Contains(got, s) {
+			t.Errorf("%q not present in:\n%s", s, got)
+		}
+	}
+}
+
+// rejectIR reports an error for each of the given substrings present in the
+// LLVM IR assembly.
+func rejectIR(t *testing.T, got string, unwanted ...string) {
+	t.Helper()
+	for _, s := range unwanted {
+		if strings.Contains(got, s) {
+			t.Errorf("%q unexpectedly present in:\n%s", s, got)
+		}
+	}
+}
+
+func TestShadowedBuiltin(t *testing.T) {
+	const src = `package p
+
+func f(s string) int {
+	len := func(s string) int { return 42 }
+	return len(s)
+}
+`
+	def := mustFuncDef(t, lowerSource(t, src), "f")
+	// The local variable is called, not the builtin function.
+	wantIR(t, def, "call i64")
+	rejectIR(t, def, "extractvalue")
+}
+
+func TestSourceFilename(t *testing.T) {
+	const src = `package p
+`
+	module := lowerSource(t, src)
+	wantIR(t, module, `source_filename = "`, `p.go"`)
+}
@@ -0,0 +1,233 @@
+package lower
+
+import (
+	"go/ast"
+	gotypes "go/types"
+
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/mewspring/toy/irgen"
+	"github.com/pkg/errors"
+)
+
+// mapType returns the LLVM IR type of maps, which is a pointer to a runtime map
+// object. The key and element types are not part of the representation, as map
+// operations are handled by the runtime.
+func (gen *Generator) mapType() types.Type {
+	const name = "toy.map"
+	t, ok := gen.typeDefs[name]
+	if !ok {
+		t = &types.StructType{Opaque: true}
+		t.SetName(name)
+		gen.typeDefs[name] = t
+	}
+	return types.NewPointer(t)
+}
+
+// newMap creates a new map of the given Go map type with the given size hint,
+// emitting to f.
+//
+// The runtime uses the type descriptor of the map type to hash and compare
+// keys, and the sizes in bytes of the key and element types to allocate
+// buckets.
+//
+//	declare %toy.map* @toy.makemap(i8* %typedesc, i64 %keysize, i64 %elemsize, i64 %hint)
+func (fgen *funcGen) newMap(goType gotypes.Type, hint value.Value) (value.Value, error) {
+	goMapType := goType.Underlying().(*gotypes.Map)
+	keyType, err := fgen.gen.irType(goMapType.Key())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	elemType, err := fgen.gen.irType(goMapType.Elem())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	i8Ptr := types.NewPointer(types.I8)
+	makemap := fgen.gen.runtimeFunc("makemap", fgen.gen.mapType(), i8Ptr, types.I64, types.I64, types.I64)
+	typ := fgen.gen.typeDesc(goType)
+	return fgen.cur.NewCall(makemap, typ, sizeof(keyType), sizeof(elemType), hint), nil
+}
+
+// lowerMapLit lowers the Go map composite literal to LLVM IR, emitting to f.
+//
+// The map is created by the runtime, after which each key-value pair is stored
+// through a pointer to the key and element respectively.
+//
+//	m := toy.makemap(typedesc, keysize, elemsize, len(elts))
+//	toy.mapset(m, &key, &elem) // for each key-value pair
+func (fgen *funcGen) lowerMapLit(goLit *ast.CompositeLit, goType gotypes.Type) (value.Value, error) {
+	goMapType := goType.Underlying().(*gotypes.Map)
+	t, err := fgen.gen.irType(goType)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	i8Ptr := types.NewPointer(types.I8)
+	mapset := fgen.gen.runtimeFunc("mapset", types.Void, fgen.gen.mapType(), i8Ptr, i8Ptr)
+	hint := constant.NewInt(types.I64, int64(len(goLit.Elts)))
+	m, err := fgen.newMap(goType, hint)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for _, goElt := range goLit.Elts {
+		goKeyValue, ok := goElt.(*ast.KeyValueExpr)
+		if !ok {
+			return nil, errors.Errorf("invalid map literal element; expected key-value pair, got %T", goElt)
+		}
+		key, err := fgen.lowerArg(goKeyValue.Key, goMapType.Key())
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		elem, err := fgen.lowerArg(goKeyValue.Value, goMapType.Elem())
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		keyMem := fgen.newLocal(key.Type())
+		fgen.newStore(key, keyMem)
+		elemMem := fgen.newLocal(elem.Type())
+		fgen.newStore(elem, elemMem)
+		keyPtr := fgen.cur.NewBitCast(keyMem, i8Ptr)
+		elemPtr := fgen.cur.NewBitCast(elemMem, i8Ptr)
+		fgen.cur.NewCall(mapset, m, keyPtr, elemPtr)
+	}
+	if !types.Equal(m.Type(), t) {
+		// Named map type.
+		m = fgen.cur.NewBitCast(m, t)
+	}
+	return m, nil
+}
+
+// lowerMapKey lowers the map operand and key of the Go map index expression to
+// LLVM IR, emitting to f. The returned values are the map and a pointer to a
+// copy of the key, as passed to the runtime.
+func (fgen *funcGen) lowerMapKey(goExpr *ast.IndexExpr, goMapType *gotypes.Map) (m, keyPtr value.Value, err error) {
+	m, err = fgen.lowerExprUse(goExpr.X)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	key, err := fgen.lowerArg(goExpr.Index, goMapType.Key())
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	if !types.Equal(m.Type(), fgen.gen.mapType()) {
+		// Named map type.
+		m = fgen.cur.NewBitCast(m, fgen.gen.mapType())
+	}
+	keyMem := fgen.newLocal(key.Type())
+	fgen.newStore(key, keyMem)
+	keyPtr = fgen.cur.NewBitCast(keyMem, types.NewPointer(types.I8))
+	return m, keyPtr, nil
+}
+
+// lowerMapIndex lowers the Go index expression of a map operand to LLVM IR,
+// emitting to f. The runtime returns a pointer to the element of the given key,
+// or to the zero value of the element type if not present.
+//
+//	elem := *(*T)(toy.mapaccess(m, &key))
+//
+// The comma-ok form (e.g. `v, ok := m[k]`) additionally reports whether the
+// key is present, and is lowered to a struct value holding both results.
+//
+//	elem, ok := toy.mapaccess2(m, &key)
+func (fgen *funcGen) lowerMapIndex(goExpr *ast.IndexExpr, goMapType *gotypes.Map) (value.Value, error) {
+	m, keyPtr, err := fgen.lowerMapKey(goExpr, goMapType)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// Comma-ok form; the type-checker records the type of the index expression
+	// as a tuple of the element type and bool.
+	if _, ok := fgen.gen.pkg.TypesInfo.TypeOf(goExpr).(*gotypes.Tuple); ok {
+		elemType, err := fgen.gen.irType(goMapType.Elem())
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		i8Ptr := types.NewPointer(types.I8)
+		mapaccess2 := fgen.gen.runtimeFunc("mapaccess2", types.NewStruct(i8Ptr, types.I1), fgen.gen.mapType(), i8Ptr)
+		result := fgen.cur.NewCall(mapaccess2, m, keyPtr)
+		elemPtr := fgen.cur.NewExtractValue(result, 0)
+		present := fgen.cur.NewExtractValue(result, 1)
+		elemMem := fgen.cur.NewBitCast(elemPtr, types.NewPointer(elemType))
+		elem := fgen.newLoad(elemMem)
+		t := types.NewStruct(elemType, types.I1)
+		return irgen.NewAggregate(fgen.cur, t, elem, present), nil
+	}
+	return fgen.mapAccess(m, keyPtr, goMapType)
+}
+
+// mapAccess returns the element of the given key in the map m, or the zero
+// value of the element type if not present, emitting to f.
+func (fgen *funcGen) mapAccess(m, keyPtr value.Value, goMapType *gotypes.Map) (value.Value, error) {
+	elemType, err := fgen.gen.irType(goMapType.Elem())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	i8Ptr := types.NewPointer(types.I8)
+	mapaccess := fgen.gen.runtimeFunc("mapaccess", i8Ptr, fgen.gen.mapType(), i8Ptr)
+	elemPtr := fgen.cur.NewCall(mapaccess, m, keyPtr)
+	elemMem := fgen.cur.NewBitCast(elemPtr, types.NewPointer(elemType))
+	return fgen.newLoad(elemMem), nil
+}
+
+// lowerMapAssign lowers the assignment of v to the element of the Go map index
+// expression (e.g. `m[k] = v`) to LLVM IR, emitting to f. Map elements are not
+// addressable, thus the element is stored by the runtime.
+//
+//	toy.mapset(m, &key, &elem)
+func (fgen *funcGen) lowerMapAssign(goExpr *ast.IndexExpr, v value.Value) error {
+	goMapType := fgen.gen.subst(fgen.gen.pkg.TypesInfo.TypeOf(goExpr.X)).Underlying().(*gotypes.Map)
+	m, keyPtr, err := fgen.lowerMapKey(goExpr, goMapType)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	fgen.mapSet(m, keyPtr, v)
+	return nil
+}
+
+// lowerMapUpdate lowers the read-modify-write operation of the element of the
+// Go map index expression (e.g. `m[k] += v` or `m[k]++`) to LLVM IR, emitting
+// to f. The map operand and key are evaluated once, and the new value of the
+// element is computed from the current value (or zero value if not present) by
+// update.
+func (fgen *funcGen) lowerMapUpdate(goExpr *ast.IndexExpr, update func(x value.Value) (value.Value, error)) error {
+	goMapType := fgen.gen.subst(fgen.gen.pkg.TypesInfo.TypeOf(goExpr.X)).Underlying().(*gotypes.Map)
+	m, keyPtr, err := fgen.lowerMapKey(goExpr, goMapType)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	x, err := fgen.mapAccess(m, keyPtr, goMapType)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	fgen.goTypes[x] = goMapType.Elem()
+	result, err := update(x)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	fgen.mapSet(m, keyPtr, result)
+	return nil
+}
+
+// mapSet stores the element v of the given key in the map m, emitting to f.
+func (fgen *funcGen) mapSet(m, keyPtr, v value.Value) {
+	i8Ptr := types.NewPointer(types.I8)
+	mapset := fgen.gen.runtimeFunc("mapset", types.Void, fgen.gen.mapType(), i8Ptr, i8Ptr)
+	elemMem := fgen.newLocal(v.Type())
+	fgen.newStore(v, elemMem)
+	elemPtr := fgen.cur.NewBitCast(elemMem, i8Ptr)
+	fgen.cur.NewCall(mapset, m, keyPtr, elemPtr)
+}
+
+// mapIndexExpr returns the Go index expression of a map operand denoted by the
+// given expression (e.g. `m[k]` or `(m[k])`). The boolean return value
+// indicates success.
+func (fgen *funcGen) mapIndexExpr(goExpr ast.Expr) (*ast.IndexExpr, bool) {
+	goIndexExpr, ok := unparen(goExpr).(*ast.IndexExpr)
+	if !ok {
+		return nil, false
+	}
+	goType := fgen.gen.subst(fgen.gen.pkg.TypesInfo.TypeOf(goIndexExpr.X))
+	if _, ok := goType.Underlying().(*gotypes.Map); !ok {
+		return nil, false
+	}
+	return goIndexExpr, true
+}
@@ -0,0 +1,51 @@
+package lower
+
+import "testing"
+
+func TestMapLit(t *testing.T) {
+	const src = `package p
+
+func f() map[string]int {
+	return map[string]int{"a": 1, "b": 2}
+}
+`
+	def := mustFuncDef(t, lowerSource(t, src), "f")
+	// The key and element sizes are passed to the runtime, followed by the size
+	// hint.
+	wantIR(t, def, "call %toy.map* @toy.makemap(i8* ", "getelementptr (i64, i64* null, i64 1) to i64), i64 2)")
+	wantCount(t, def, "call void @toy.mapset(", 2)
+}
+
+func TestMapAssign(t *testing.T) {
+	const src = `package p
+
+func f(m map[string]int) {
+	m["a"] = 1
+	m["b"] += 2
+	m["c"]++
+}
+`
+	def := mustFuncDef(t, lowerSource(t, src), "f")
+	// Map elements are not addressable; each element is stored by the runtime,
+	// and compound assignments first read the current element.
+	wantCount(t, def, "call void @toy.mapset(", 3)
+	wantCount(t, def, "call i8* @toy.mapaccess(", 2)
+}
+
+func TestMapCommaOkIf(t *testing.T) {
+	const src = `package p
+
+func use(v int) {}
+
+func f(m map[string]int, k string) {
+	if v, ok := m[k]; ok {
+		use(v)
+	}
+}
+`
+	def := mustFuncDef(t, lowerSource(t, src), "f")
+	// Both the element and whether the key is present are bound by the if init,
+	// and the latter is used as condition.
+	wantIR(t, def, "call { i8*, i1 } @toy.mapaccess2(%toy.map* ", "br i1 ", "call void @use(i64 ")
+	rejectIR(t, def, "@toy.mapaccess(")
+}
@@ -0,0 +1,275 @@
+package lower
+
+import (
+	"fmt"
+	"go/ast"
+	gotypes "go/types"
+	"strings"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/pkg/errors"
+)
+
+// methodName returns the LLVM IR function name of the given Go method; i.e.
+// "T.M" for the method M of receiver type T or *T. Method names are unique per
+// receiver base type, as T and *T may not declare methods of the same name.
+//
+// Methods of generic type instances are qualified by the type arguments of the
+// receiver base type (e.g. "Stack[int].Push").
+func (gen *Generator) methodName(fn *gotypes.Func) string {
+	recvType := gen.subst(fn.Type().(*gotypes.Signature).Recv().Type())
+	if goPtrType, ok := recvType.(*gotypes.Pointer); ok {
+		recvType = goPtrType.Elem()
+	}
+	typeName := recvType.String()
+	if goNamedType, ok := recvType.(*gotypes.Named); ok {
+		typeName = goNamedType.Obj().Name()
+		if goTypeArgs := goNamedType.TypeArgs(); goTypeArgs.Len() > 0 {
+			var argNames []string
+			for i := 0; i < goTypeArgs.Len(); i++ {
+				argNames = append(argNames, gotypes.TypeString(goTypeArgs.At(i), gen.qualifier))
+			}
+			typeName = fmt.Sprintf("%s[%s]", typeName, strings.Join(argNames, ","))
+		}
+	}
+	return fmt.Sprintf("%s.%s", typeName, fn.Name())
+}
+
+// recvBaseType returns the named base type of the given Go receiver type T or
+// *T. The boolean return value indicates success.
+func recvBaseType(recvType gotypes.Type) (*gotypes.Named, bool) {
+	if goPtrType, ok := recvType.(*gotypes.Pointer); ok {
+		recvType = goPtrType.Elem()
+	}
+	goNamedType, ok := recvType.(*gotypes.Named)
+	return goNamedType, ok
+}
+
+// method returns the LLVM IR function of the given Go method, instantiating
+// methods of generic type instances on first use.
+func (gen *Generator) method(fn *gotypes.Func) (*ir.Function, error) {
+	if goNamedType, ok := recvBaseType(fn.Type().(*gotypes.Signature).Recv().Type()); ok && goNamedType.TypeArgs().Len() > 0 {
+		return gen.instantiateMethod(fn)
+	}
+	funcName := gen.methodName(fn)
+	f, ok := gen.funcs[funcName]
+	if !ok {
+		return nil, errors.Errorf("unable to locate method definition %q", funcName)
+	}
+	return f, nil
+}
+
+// lowerMethodCall lowers the Go call expression of the method selected by sel
+// (e.g. `x.M()`) to LLVM IR, emitting to f. The receiver is passed as the first
+// argument; taking the address of or dereferencing the receiver operand as
+// required by the receiver type of the method.
+func (fgen *funcGen) lowerMethodCall(goCallExpr *ast.CallExpr, goSelExpr *ast.SelectorExpr, sel *gotypes.Selection) (value.Value, error) {
+	goRecvType := fgen.gen.pkg.TypesInfo.TypeOf(goSelExpr.X)
+	if gotypes.IsInterface(goRecvType) {
+		return fgen.lowerIfaceMethodCall(goCallExpr, goSelExpr, sel)
+	}
+	fn := sel.Obj().(*gotypes.Func)
+	if len(sel.Index()) > 1 {
+		panic(fmt.Errorf("support for calls to promoted method %q not yet implemented", fn.Name()))
+	}
+	f, err := fgen.gen.method(fn)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	_, ptrRecv := fn.Type().(*gotypes.Signature).Recv().Type().(*gotypes.Pointer)
+	_, ptrOperand := goRecvType.Underlying().(*gotypes.Pointer)
+	var recv value.Value
+	switch {
+	case ptrRecv && !ptrOperand:
+		// Implicit address of addressable operand (e.g. `x.M()` for `(&x).M()`).
+		recv, err = fgen.lowerExprAddr(goSelExpr.X)
+	case !ptrRecv && ptrOperand:
+		// Implicit dereference of pointer operand (e.g. `p.M()` for `(*p).M()`).
+		recv, err = fgen.lowerExprUse(goSelExpr.X)
+		if err == nil {
+			fgen.checkNil(recv)
+			recv = fgen.newLoad(recv)
+		}
+	default:
+		recv, err = fgen.lowerExprUse(goSelExpr.X)
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	args, err := fgen.lowerCallArgs(goCallExpr)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return fgen.cur.NewCall(f, append([]value.Value{recv}, args...)...), nil
+}
+
+// lowerIfaceMethodCall lowers the Go call expression of the method selected by
+// sel on an interface value (e.g. `s.String()` for `s` of type fmt.Stringer) to
+// LLVM IR, emitting to f.
+//
+// The method is located through the itable of the interface value, and invoked
+// with the data word of the interface value as receiver.
+//
+//	fn := itab.methods[i]
+//	fn(data, args...)
+func (fgen *funcGen) lowerIfaceMethodCall(goCallExpr *ast.CallExpr, goSelExpr *ast.SelectorExpr, sel *gotypes.Selection) (value.Value, error) {
+	goIface := fgen.gen.pkg.TypesInfo.TypeOf(goSelExpr.X).Underlying().(*gotypes.Interface)
+	x, err := fgen.lowerExprUse(goSelExpr.X)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	index, ok := ifaceMethodIndex(goIface, sel.Obj().Name())
+	if !ok {
+		return nil, errors.Errorf("unable to locate method %q of interface %v", sel.Obj().Name(), goIface)
+	}
+	itabType, err := fgen.gen.itabType(goIface)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	itab := fgen.cur.NewExtractValue(x, 0)
+	data := fgen.cur.NewExtractValue(x, 1)
+	tab := fgen.cur.NewBitCast(itab, types.NewPointer(itabType))
+	zero := constant.NewInt(types.I32, 0)
+	// The first field of the itable holds the type descriptor of the dynamic
+	// type, followed by the methods of the interface.
+	fnPtr := fgen.cur.NewGetElementPtr(tab, zero, constant.NewInt(types.I32, int64(1+index)))
+	fn := fgen.newLoad(fnPtr)
+	args, err := fgen.lowerCallArgs(goCallExpr)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return fgen.cur.NewCall(fn, append([]value.Value{data}, args...)...), nil
+}
+
+// itab returns a pointer to the itable of the given Go concrete type for the
+// Go interface type, adding the itable to the module on first use.
+//
+// The itable holds the type descriptor of the concrete type, followed by
+// pointers to the methods of the concrete type in the method order of the
+// interface. Each method is invoked through a wrapper function which loads the
+// receiver from the data word of the interface value.
+func (gen *Generator) itab(goType gotypes.Type, goIface *gotypes.Interface) (constant.Constant, error) {
+	name := fmt.Sprintf("toy.itab.%s,%s", gotypes.TypeString(goType, gen.qualifier), gotypes.TypeString(goIface, gen.qualifier))
+	g, ok := gen.itabs[name]
+	if !ok {
+		itabType, err := gen.itabType(goIface)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		fields := []constant.Constant{gen.typeDesc(goType)}
+		for i := 0; i < goIface.NumMethods(); i++ {
+			wrapper, err := gen.ifaceMethodWrapper(goType, goIface.Method(i))
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			fields = append(fields, wrapper)
+		}
+		init := constant.NewStruct(fields...)
+		init.Typ = itabType
+		g = gen.m.NewGlobalDef(name, init)
+		g.Immutable = true
+		g.Linkage = enum.LinkageInternal
+		gen.itabs[name] = g
+	}
+	return constant.NewBitCast(g, types.NewPointer(types.I8)), nil
+}
+
+// itabType returns the LLVM IR type of itables of the given Go interface type.
+func (gen *Generator) itabType(goIface *gotypes.Interface) (*types.StructType, error) {
+	fields := []types.Type{types.NewPointer(types.I8)} // type descriptor
+	for i := 0; i < goIface.NumMethods(); i++ {
+		sig := goIface.Method(i).Type().(*gotypes.Signature)
+		methodType, err := gen.ifaceMethodType(sig)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		fields = append(fields, types.NewPointer(methodType))
+	}
+	return types.NewStruct(fields...), nil
+}
+
+// ifaceMethodType returns the LLVM IR function type of methods invoked through
+// itables, with the given Go method signature. The receiver is passed as the
+// data word of the interface value.
+func (gen *Generator) ifaceMethodType(sig *gotypes.Signature) (*types.FuncType, error) {
+	funcType, err := gen.irFuncType(sig)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	params := append([]types.Type{types.NewPointer(types.I8)}, funcType.Params...)
+	return types.NewFunc(funcType.RetType, params...), nil
+}
+
+// ifaceMethodWrapper returns the wrapper function of the method m of the given
+// Go concrete type, as invoked through itables, adding the wrapper to the
+// module on first use. The wrapper loads the receiver from the data word of the
+// interface value, and invokes the method.
+func (gen *Generator) ifaceMethodWrapper(goType gotypes.Type, m *gotypes.Func) (*ir.Function, error) {
+	wrapperName := fmt.Sprintf("%s.%s$iface", gotypes.TypeString(goType, gen.qualifier), m.Name())
+	if f, ok := gen.funcs[wrapperName]; ok {
+		return f, nil
+	}
+	obj, index, _ := gotypes.LookupFieldOrMethod(goType, false, m.Pkg(), m.Name())
+	fn, ok := obj.(*gotypes.Func)
+	if !ok {
+		return nil, errors.Errorf("unable to locate method %q of type %v", m.Name(), goType)
+	}
+	if len(index) > 1 {
+		panic(fmt.Errorf("support for promoted method %q in itable of type %v not yet implemented", m.Name(), goType))
+	}
+	method, err := gen.method(fn)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	boxedType, err := gen.irType(goType)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// Wrapper parameters; data word followed by the method parameters.
+	params := []*ir.Param{ir.NewParam("data", types.NewPointer(types.I8))}
+	for _, param := range method.Params[1:] {
+		params = append(params, ir.NewParam(param.Name(), param.Type()))
+	}
+	f := gen.m.NewFunc(wrapperName, method.Sig.RetType, params...)
+	f.Linkage = enum.LinkageInternal
+	gen.funcs[wrapperName] = f
+	// The data word points to a copy of the boxed value.
+	fgen := gen.newFuncGen()
+	fgen.f = f
+	fgen.cur = f.NewBlock("entry")
+	mem := fgen.cur.NewBitCast(params[0], types.NewPointer(boxedType))
+	var recv value.Value = fgen.newLoad(mem)
+	_, ptrRecv := fn.Type().(*gotypes.Signature).Recv().Type().(*gotypes.Pointer)
+	if _, ok := goType.Underlying().(*gotypes.Pointer); ok && !ptrRecv {
+		// Method with value receiver in the method set of pointer type.
+		fgen.checkNil(recv)
+		recv = fgen.newLoad(recv)
+	}
+	args := []value.Value{recv}
+	for _, param := range params[1:] {
+		args = append(args, param)
+	}
+	result := fgen.cur.NewCall(method, args...)
+	if types.Equal(method.Sig.RetType, types.Void) {
+		fgen.cur.NewRet(nil)
+	} else {
+		fgen.cur.NewRet(result)
+	}
+	return f, nil
+}
+
+// ifaceMethodIndex returns the index of the method with the given name in the
+// method order of the Go interface type. The boolean return value indicates
+// success.
+func ifaceMethodIndex(goIface *gotypes.Interface, name string) (int, bool) {
+	for i := 0; i < goIface.NumMethods(); i++ {
+		if goIface.Method(i).Name() == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,78 @@
+package lower
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// runtimeFuncAttrs maps from runtime helper function name to the function
+// attributes implied by the semantics of the helper, so that LLVM may optimize
+// across calls to the runtime.
+var runtimeFuncAttrs = map[string][]ir.FuncAttribute{
+	// Helpers which never return to the caller.
+	"panic":      {enum.FuncAttrNoReturn, enum.FuncAttrCold},
+	"panicindex": {enum.FuncAttrNoReturn, enum.FuncAttrCold},
+	"panicnil":   {enum.FuncAttrNoReturn, enum.FuncAttrCold},
+	"panicslice": {enum.FuncAttrNoReturn, enum.FuncAttrCold},
+	// Helpers which only read memory pointed to by their pointer arguments.
+	"convI2E": {enum.FuncAttrReadOnly, enum.FuncAttrArgMemOnly},
+	// Helpers which only read memory. The interface values compared by ifaceeq
+	// are passed by value, and the type descriptors and data they point to are
+	// thus not accessed through pointer arguments.
+	"ifaceeq": {enum.FuncAttrReadOnly},
+	"streq":   {enum.FuncAttrReadOnly},
+	"maplen":  {enum.FuncAttrReadOnly},
+	"chanlen": {enum.FuncAttrReadOnly},
+	"chancap": {enum.FuncAttrReadOnly},
+}
+
+// runtimeFunc returns the LLVM IR function declaration of the given runtime
+// helper function (e.g. "runeslicetostr" for @toy.runeslicetostr), declaring it
+// in the module on first use.
+func (gen *Generator) runtimeFunc(name string, retType types.Type, paramTypes ...types.Type) *ir.Function {
+	return gen.declareFunc("toy."+name, runtimeFuncAttrs[name], retType, paramTypes...)
+}
+
+// intrinsic returns the LLVM IR function declaration of the given LLVM
+// intrinsic function (e.g. "llvm.memmove.p0i8.p0i8.i64"), declaring it in the
+// module on first use.
+func (gen *Generator) intrinsic(name string, retType types.Type, paramTypes ...types.Type) *ir.Function {
+	return gen.declareFunc(name, nil, retType, paramTypes...)
+}
+
+// declareFunc returns the LLVM IR function declaration with the given name,
+// function attributes and signature, declaring it in the module on first use.
+func (gen *Generator) declareFunc(funcName string, attrs []ir.FuncAttribute, retType types.Type, paramTypes ...types.Type) *ir.Function {
+	if f, ok := gen.runtimeFuncs[funcName]; ok {
+		return f
+	}
+	var params []*ir.Param
+	for _, paramType := range paramTypes {
+		params = append(params, ir.NewParam("", paramType))
+	}
+	f := gen.m.NewFunc(funcName, retType, params...)
+	f.FuncAttrs = attrs
+	gen.runtimeFuncs[funcName] = f
+	return f
+}
+
+// newObject allocates memory on the heap for a value of the given type,
+// emitting to f. The returned value is a pointer to the allocated memory.
+func (fgen *funcGen) newObject(t types.Type) value.Value {
+	alloc := fgen.gen.runtimeFunc("alloc", types.NewPointer(types.I8), types.I64)
+	mem := fgen.cur.NewCall(alloc, sizeof(t))
+	return fgen.cur.NewBitCast(mem, types.NewPointer(t))
+}
+
+// sizeof returns the size in bytes of the given type, as a constant expression
+// which is independent of the target data layout. See abiSize for the size
+// computed at compile time.
+func sizeof(t types.Type) constant.Constant {
+	// ptrtoint (getelementptr (T, T* null, i64 1)) to i64
+	null := constant.NewNull(types.NewPointer(t))
+	end := constant.NewGetElementPtr(null, constant.NewInt(types.I64, 1))
+	return constant.NewPtrToInt(end, types.I64)
+}
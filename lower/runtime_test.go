@@ -0,0 +1,22 @@
+package lower
+
+import "testing"
+
+func TestPanicNoReturn(t *testing.T) {
+	const src = `package p
+
+func f() {
+	panic("unreachable")
+}
+
+func g(x, y interface{}) bool {
+	return x == y
+}
+`
+	module := lowerSource(t, src)
+	decl := funcDecl(module, "toy.panic")
+	wantIR(t, decl, "noreturn", "cold")
+	decl = funcDecl(module, "toy.ifaceeq")
+	wantIR(t, decl, "readonly")
+	rejectIR(t, decl, "noreturn", "argmemonly")
+}
@@ -0,0 +1,221 @@
+package lower
+
+import (
+	"go/ast"
+	"go/token"
+	gotypes "go/types"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/mewspring/toy/irgen"
+	"github.com/pkg/errors"
+)
+
+// selectCaseType returns the LLVM IR type of select cases passed to the
+// runtime; i.e. the channel, a pointer to the element to send or to receive
+// into, and whether the case is a send case.
+//
+//	{%toy.chan*, i8*, i1}
+func (gen *Generator) selectCaseType() types.Type {
+	return types.NewStruct(gen.chanType(), types.NewPointer(types.I8), types.I1)
+}
+
+// lowerSelectStmt lowers the Go select statement to LLVM IR, emitting to f.
+//
+// The channel operands and the values of send cases are evaluated in source
+// order upon entering the select statement, after which the runtime selects a
+// case which may proceed:
+//
+//	declare {i64, i1} @toy.selectgo({%toy.chan*, i8*, i1}* %cases, i64 %ncases, i1 %block)
+//
+// The runtime returns the index of the selected case and, for receive cases,
+// whether a value was received (false if the channel is closed and drained).
+// Without a default clause, the runtime blocks until a case may proceed;
+// otherwise, the index -1 is returned if no case may proceed, and the default
+// clause is run.
+func (fgen *funcGen) lowerSelectStmt(goSelectStmt *ast.SelectStmt) {
+	// selectCase is a send or receive case of the select statement.
+	type selectCase struct {
+		goClause *ast.CommClause
+		// Memory of the element to send or to receive into.
+		elemMem value.Value
+		// Go element type of the channel.
+		goElemType gotypes.Type
+		// Case passed to the runtime.
+		v value.Value
+	}
+	var cases []*selectCase
+	var goDefault *ast.CommClause
+	i8Ptr := types.NewPointer(types.I8)
+	caseType := fgen.gen.selectCaseType()
+	for _, goStmt := range goSelectStmt.Body.List {
+		goClause, ok := goStmt.(*ast.CommClause)
+		if !ok {
+			fgen.gen.Errorf("invalid select clause type; expected *ast.CommClause, got %T", goStmt)
+			return
+		}
+		if goClause.Comm == nil {
+			// default clause.
+			goDefault = goClause
+			continue
+		}
+		goChan, goValue, err := commOperands(goClause.Comm)
+		if err != nil {
+			fgen.gen.eh(err)
+			return
+		}
+		ch, err := fgen.lowerExprUse(goChan)
+		if err != nil {
+			fgen.gen.eh(err)
+			return
+		}
+		goChanType := fgen.gen.subst(fgen.gen.pkg.TypesInfo.TypeOf(goChan)).Underlying().(*gotypes.Chan)
+		elemType, err := fgen.gen.irType(goChanType.Elem())
+		if err != nil {
+			fgen.gen.eh(err)
+			return
+		}
+		elemMem := fgen.newLocal(elemType)
+		send := constant.False
+		if goValue != nil {
+			// Send case.
+			v, err := fgen.lowerArg(goValue, goChanType.Elem())
+			if err != nil {
+				fgen.gen.eh(err)
+				return
+			}
+			fgen.newStore(v, elemMem)
+			send = constant.True
+		}
+		elem := fgen.cur.NewBitCast(elemMem, i8Ptr)
+		c := &selectCase{
+			goClause:   goClause,
+			elemMem:    elemMem,
+			goElemType: goChanType.Elem(),
+			v:          irgen.NewAggregate(fgen.cur, caseType, ch, elem, send),
+		}
+		cases = append(cases, c)
+	}
+	// Select a case which may proceed.
+	casesMem := fgen.newLocal(types.NewArray(uint64(len(cases)), caseType))
+	zero := constant.NewInt(types.I64, 0)
+	for i, c := range cases {
+		dst := fgen.cur.NewGetElementPtr(casesMem, zero, constant.NewInt(types.I64, int64(i)))
+		fgen.newStore(c.v, dst)
+	}
+	selectgo := fgen.gen.runtimeFunc("selectgo", types.NewStruct(types.I64, types.I1), types.NewPointer(caseType), types.I64, types.I1)
+	casesPtr := fgen.cur.NewGetElementPtr(casesMem, zero, zero)
+	ncases := constant.NewInt(types.I64, int64(len(cases)))
+	block := constant.NewBool(goDefault == nil)
+	result := fgen.cur.NewCall(selectgo, casesPtr, ncases, block)
+	index := fgen.cur.NewExtractValue(result, 0)
+	recvOK := fgen.cur.NewExtractValue(result, 1)
+	//followBlock := ir.NewBlock("follow")
+	followBlock := ir.NewBlock("")
+	targetDefault := followBlock
+	var defaultBlock *ir.BasicBlock
+	if goDefault != nil {
+		//defaultBlock = ir.NewBlock("default")
+		defaultBlock = ir.NewBlock("")
+		targetDefault = defaultBlock
+	}
+	var caseBlocks []*ir.BasicBlock
+	var irCases []*ir.Case
+	for i := range cases {
+		//caseBlock := ir.NewBlock(fmt.Sprintf("case_%d", i))
+		caseBlock := ir.NewBlock("")
+		caseBlocks = append(caseBlocks, caseBlock)
+		irCases = append(irCases, ir.NewCase(constant.NewInt(types.I64, int64(i)), caseBlock))
+	}
+	fgen.cur.NewSwitch(index, targetDefault, irCases...)
+	// Case bodies.
+	pop := fgen.pushTarget(goSelectStmt, followBlock, nil)
+	for i, c := range cases {
+		fgen.f.Blocks = append(fgen.f.Blocks, caseBlocks[i])
+		fgen.cur = caseBlocks[i]
+		if goAssignStmt, ok := c.goClause.Comm.(*ast.AssignStmt); ok {
+			// Receive case with assignment (e.g. `case v, ok := <-ch:`).
+			fgen.lowerRecvAssign(goAssignStmt, fgen.newLoad(c.elemMem), c.goElemType, recvOK)
+		}
+		fgen.lowerCommBody(c.goClause, followBlock)
+	}
+	if goDefault != nil {
+		fgen.f.Blocks = append(fgen.f.Blocks, defaultBlock)
+		fgen.cur = defaultBlock
+		fgen.lowerCommBody(goDefault, followBlock)
+	}
+	pop()
+	// Follow basic block.
+	fgen.cur = followBlock
+	fgen.f.Blocks = append(fgen.f.Blocks, followBlock)
+}
+
+// lowerCommBody lowers the body of the Go select clause to LLVM IR, emitting to
+// f. Control is transferred to the follow basic block at the end of the body.
+func (fgen *funcGen) lowerCommBody(goClause *ast.CommClause, followBlock *ir.BasicBlock) {
+	for _, goStmt := range goClause.Body {
+		fgen.lowerStmt(goStmt)
+	}
+	if fgen.cur.Term == nil {
+		fgen.cur.NewBr(followBlock)
+	}
+}
+
+// lowerRecvAssign assigns the received element and the ok flag of a receive
+// operation to the left-hand side operands of the Go assignment statement
+// (e.g. `v, ok := <-ch` or `v = <-ch`), emitting to f.
+func (fgen *funcGen) lowerRecvAssign(goAssignStmt *ast.AssignStmt, elem value.Value, goElemType gotypes.Type, recvOK value.Value) {
+	info := fgen.gen.pkg.TypesInfo
+	vs := []value.Value{elem, recvOK}
+	goTypes := []gotypes.Type{goElemType, gotypes.Typ[gotypes.Bool]}
+	for i, goLhs := range goAssignStmt.Lhs {
+		if isBlank(goLhs) {
+			continue
+		}
+		v, err := fgen.convert(vs[i], goTypes[i], info.TypeOf(goLhs))
+		if err != nil {
+			fgen.gen.eh(err)
+			continue
+		}
+		var mem value.Value
+		if goIdent, ok := goLhs.(*ast.Ident); ok && goAssignStmt.Tok == token.DEFINE && info.Defs[goIdent] != nil {
+			// New variable scoped to the select clause.
+			mem = fgen.newVar(info.Defs[goIdent], v.Type())
+		} else {
+			mem, err = fgen.lowerExprAddr(goLhs)
+			if err != nil {
+				fgen.gen.eh(err)
+				continue
+			}
+		}
+		fgen.newStore(v, mem)
+	}
+}
+
+// commOperands returns the channel operand and the value to send (nil for
+// receive operations) of the given communication of a Go select clause; i.e. a
+// send statement (e.g. `ch <- v`) or receive operation (e.g. `<-ch`,
+// `v := <-ch` or `v, ok = <-ch`).
+func commOperands(goComm ast.Stmt) (goChan, goValue ast.Expr, err error) {
+	var goRecv ast.Expr
+	switch goComm := goComm.(type) {
+	case *ast.SendStmt:
+		return goComm.Chan, goComm.Value, nil
+	case *ast.ExprStmt:
+		goRecv = goComm.X
+	case *ast.AssignStmt:
+		if len(goComm.Rhs) != 1 {
+			return nil, nil, errors.Errorf("invalid receive operation of select clause; expected single right-hand side operand, got %d", len(goComm.Rhs))
+		}
+		goRecv = goComm.Rhs[0]
+	default:
+		return nil, nil, errors.Errorf("invalid communication of select clause; expected send or receive, got %T", goComm)
+	}
+	goUnaryExpr, ok := unparen(goRecv).(*ast.UnaryExpr)
+	if !ok || goUnaryExpr.Op != token.ARROW {
+		return nil, nil, errors.Errorf("invalid receive operation of select clause; expected '<-' unary expression, got %T", goRecv)
+	}
+	return goUnaryExpr.X, nil, nil
+}
@@ -3,8 +3,11 @@ package lower
 import (
 	"fmt"
 	"go/ast"
+	"go/token"
+	gotypes "go/types"
 
 	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
 	"github.com/llir/llvm/ir/enum"
 	"github.com/llir/llvm/ir/types"
 	"github.com/llir/llvm/ir/value"
@@ -15,11 +18,14 @@ import (
 // lowerStmt lowers the Go statement to LLVM IR, emitting to f.
 func (fgen *funcGen) lowerStmt(goStmt ast.Stmt) {
 	switch goStmt := goStmt.(type) {
-	//case *ast.AssignStmt:
+	case *ast.AssignStmt:
+		fgen.lowerAssignStmt(goStmt)
 	case *ast.BlockStmt:
 		fgen.lowerBlockStmt(goStmt)
-	//case *ast.BranchStmt:
-	//case *ast.DeclStmt:
+	case *ast.BranchStmt:
+		fgen.lowerBranchStmt(goStmt)
+	case *ast.DeclStmt:
+		fgen.lowerDeclStmt(goStmt)
 	//case *ast.DeferStmt:
 	case *ast.EmptyStmt:
 		// nothing to do.
@@ -30,12 +36,16 @@ func (fgen *funcGen) lowerStmt(goStmt ast.Stmt) {
 	//case *ast.GoStmt:
 	case *ast.IfStmt:
 		fgen.lowerIfStmt(goStmt)
-	//case *ast.IncDecStmt:
-	//case *ast.LabeledStmt:
-	//case *ast.RangeStmt:
+	case *ast.IncDecStmt:
+		fgen.lowerIncDecStmt(goStmt)
+	case *ast.LabeledStmt:
+		fgen.lowerLabeledStmt(goStmt)
+	case *ast.RangeStmt:
+		fgen.lowerRangeStmt(goStmt)
 	case *ast.ReturnStmt:
 		fgen.lowerReturnStmt(goStmt)
-	//case *ast.SelectStmt:
+	case *ast.SelectStmt:
+		fgen.lowerSelectStmt(goStmt)
 	//case *ast.SendStmt:
 	case *ast.SwitchStmt:
 		fgen.lowerSwitchStmt(goStmt)
@@ -45,6 +55,206 @@ func (fgen *funcGen) lowerStmt(goStmt ast.Stmt) {
 	}
 }
 
+// lowerAssignStmt lowers the Go assignment statement to LLVM IR, emitting to f.
+func (fgen *funcGen) lowerAssignStmt(goAssignStmt *ast.AssignStmt) {
+	switch goAssignStmt.Tok {
+	case token.ASSIGN: // =
+		fgen.lowerAssign(goAssignStmt)
+	case token.DEFINE: // :=
+		fgen.lowerDefineStmt(goAssignStmt)
+	case token.ADD_ASSIGN, token.SUB_ASSIGN, token.MUL_ASSIGN, token.QUO_ASSIGN, token.REM_ASSIGN, token.AND_ASSIGN, token.OR_ASSIGN, token.XOR_ASSIGN, token.SHL_ASSIGN, token.SHR_ASSIGN, token.AND_NOT_ASSIGN:
+		fgen.lowerOpAssign(goAssignStmt)
+	default:
+		panic(fmt.Errorf("support for assignment statement with operator %q not yet implemented", goAssignStmt.Tok))
+	}
+}
+
+// lowerAssign lowers the Go assignment statement with the `=` operator to LLVM
+// IR, emitting to f.
+//
+// All right-hand side values are evaluated before storing to the left-hand side
+// operands, so that tuple assignments (e.g. `a, b = b, a`) swap correctly.
+func (fgen *funcGen) lowerAssign(goAssignStmt *ast.AssignStmt) {
+	if fgen.lowerAggregateCopy(goAssignStmt) {
+		return
+	}
+	vs, err := fgen.lowerAssignValues(goAssignStmt.Lhs, goAssignStmt.Rhs)
+	if err != nil {
+		fgen.gen.eh(err)
+		return
+	}
+	for i, goLhs := range goAssignStmt.Lhs {
+		if isBlank(goLhs) {
+			continue
+		}
+		if goIndexExpr, ok := fgen.mapIndexExpr(goLhs); ok {
+			// Map element (e.g. `m[k] = v`).
+			if err := fgen.lowerMapAssign(goIndexExpr, vs[i]); err != nil {
+				fgen.gen.eh(err)
+			}
+			continue
+		}
+		mem, err := fgen.lowerExprAddr(goLhs)
+		if err != nil {
+			fgen.gen.eh(err)
+			continue
+		}
+		fgen.newStore(vs[i], mem)
+	}
+}
+
+// maxAggregateStore is the maximum size in bytes of struct and array values
+// copied using load and store; larger values are copied using memcpy.
+const maxAggregateStore = 64
+
+// lowerAggregateCopy lowers the Go assignment statement of a large struct or
+// array value between addressable operands (e.g. `a = b`) to a memcpy of the
+// value, emitting to f. The boolean return value indicates whether the
+// assignment was lowered; other assignments copy the value using load and
+// store.
+func (fgen *funcGen) lowerAggregateCopy(goAssignStmt *ast.AssignStmt) bool {
+	if len(goAssignStmt.Lhs) != 1 || len(goAssignStmt.Rhs) != 1 {
+		return false
+	}
+	goLhs, goRhs := goAssignStmt.Lhs[0], goAssignStmt.Rhs[0]
+	if isBlank(goLhs) || !fgen.isAddressable(goRhs) {
+		return false
+	}
+	info := fgen.gen.pkg.TypesInfo
+	goType := info.TypeOf(goLhs)
+	if !gotypes.Identical(goType, info.TypeOf(goRhs)) {
+		return false
+	}
+	switch goType.Underlying().(type) {
+	case *gotypes.Struct, *gotypes.Array:
+		// aggregate value.
+	default:
+		return false
+	}
+	typ, err := fgen.gen.irType(goType)
+	if err != nil {
+		fgen.gen.eh(err)
+		return true
+	}
+	size := abiSize(typ)
+	if size <= maxAggregateStore {
+		return false
+	}
+	src, err := fgen.lowerExprAddr(goRhs)
+	if err != nil {
+		fgen.gen.eh(err)
+		return true
+	}
+	dst, err := fgen.lowerExprAddr(goLhs)
+	if err != nil {
+		fgen.gen.eh(err)
+		return true
+	}
+	// The source and destination either coincide (e.g. `a = a`) or are
+	// disjoint, as required by memcpy.
+	i8Ptr := types.NewPointer(types.I8)
+	memcpy := fgen.gen.intrinsic("llvm.memcpy.p0i8.p0i8.i64", types.Void, i8Ptr, i8Ptr, types.I64, types.I1)
+	dstPtr := fgen.cur.NewBitCast(dst, i8Ptr)
+	srcPtr := fgen.cur.NewBitCast(src, i8Ptr)
+	fgen.cur.NewCall(memcpy, dstPtr, srcPtr, constant.NewInt(types.I64, int64(size)), constant.False)
+	return true
+}
+
+// assignOps maps from compound assignment operator to binary operator.
+var assignOps = map[token.Token]token.Token{
+	token.ADD_ASSIGN:     token.ADD,     // +=
+	token.SUB_ASSIGN:     token.SUB,     // -=
+	token.MUL_ASSIGN:     token.MUL,     // *=
+	token.QUO_ASSIGN:     token.QUO,     // /=
+	token.REM_ASSIGN:     token.REM,     // %=
+	token.AND_ASSIGN:     token.AND,     // &=
+	token.OR_ASSIGN:      token.OR,      // |=
+	token.XOR_ASSIGN:     token.XOR,     // ^=
+	token.SHL_ASSIGN:     token.SHL,     // <<=
+	token.SHR_ASSIGN:     token.SHR,     // >>=
+	token.AND_NOT_ASSIGN: token.AND_NOT, // &^=
+}
+
+// lowerOpAssign lowers the Go compound assignment statement (e.g. `x += y`) to
+// LLVM IR, emitting to f. The left-hand side operand is evaluated once.
+func (fgen *funcGen) lowerOpAssign(goAssignStmt *ast.AssignStmt) {
+	if len(goAssignStmt.Lhs) != 1 || len(goAssignStmt.Rhs) != 1 {
+		fgen.gen.Errorf("invalid compound assignment; expected single operand on each side, got %d and %d", len(goAssignStmt.Lhs), len(goAssignStmt.Rhs))
+		return
+	}
+	update := func(x value.Value) (value.Value, error) {
+		y, err := fgen.lowerExprUse(goAssignStmt.Rhs[0])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return fgen.lowerBinaryOp(assignOps[goAssignStmt.Tok], x, y)
+	}
+	if goIndexExpr, ok := fgen.mapIndexExpr(goAssignStmt.Lhs[0]); ok {
+		// Map element (e.g. `m[k] += v`).
+		if err := fgen.lowerMapUpdate(goIndexExpr, update); err != nil {
+			fgen.gen.eh(err)
+		}
+		return
+	}
+	mem, x, err := fgen.lowerExprAddrUse(goAssignStmt.Lhs[0])
+	if err != nil {
+		fgen.gen.eh(err)
+		return
+	}
+	result, err := update(x)
+	if err != nil {
+		fgen.gen.eh(err)
+		return
+	}
+	fgen.newStore(result, mem)
+}
+
+// lowerDefineStmt lowers the Go short variable declaration to LLVM IR, emitting
+// to f.
+//
+// A short variable declaration may redeclare variables of the same scope (e.g.
+// err in `b, err := g()`), provided that at least one new variable is declared.
+// Redeclared variables are assigned to, and stack memory is only allocated for
+// the new variables.
+func (fgen *funcGen) lowerDefineStmt(goAssignStmt *ast.AssignStmt) {
+	// Evaluate the right-hand side before declaring the variables of the
+	// left-hand side, as the values may refer to shadowed variables (e.g.
+	// `x := x + 1` in an inner scope).
+	vs, err := fgen.lowerAssignValues(goAssignStmt.Lhs, goAssignStmt.Rhs)
+	if err != nil {
+		fgen.gen.eh(err)
+		return
+	}
+	for i, goLhs := range goAssignStmt.Lhs {
+		if isBlank(goLhs) {
+			continue
+		}
+		goIdent, ok := goLhs.(*ast.Ident)
+		if !ok {
+			fgen.gen.Errorf("invalid left-hand side of short variable declaration; expected *ast.Ident, got %T", goLhs)
+			continue
+		}
+		var mem value.Value
+		if obj := fgen.gen.pkg.TypesInfo.Defs[goIdent]; obj != nil {
+			// New variable.
+			t, err := fgen.gen.irType(obj.Type())
+			if err != nil {
+				fgen.gen.eh(err)
+				continue
+			}
+			mem = fgen.newVar(obj, t)
+		} else {
+			// Redeclared variable.
+			mem, err = fgen.lowerExprAddr(goIdent)
+			if err != nil {
+				fgen.gen.eh(err)
+				continue
+			}
+		}
+		fgen.newStore(vs[i], mem)
+	}
+}
+
 // lowerBlockStmt lowers the Go block statement to LLVM IR, emitting to f.
 func (fgen *funcGen) lowerBlockStmt(goBlockStmt *ast.BlockStmt) {
 	// TODO: handle scope?
@@ -53,6 +263,115 @@ func (fgen *funcGen) lowerBlockStmt(goBlockStmt *ast.BlockStmt) {
 	}
 }
 
+// lowerBranchStmt lowers the Go branch statement to LLVM IR, emitting to f.
+func (fgen *funcGen) lowerBranchStmt(goBranchStmt *ast.BranchStmt) {
+	var label string
+	if goBranchStmt.Label != nil {
+		label = goBranchStmt.Label.Name
+	}
+	var target *ir.BasicBlock
+	switch goBranchStmt.Tok {
+	case token.BREAK:
+		// Innermost enclosing for, range or switch statement, or the enclosing
+		// statement with the given label.
+		for i := len(fgen.targets) - 1; i >= 0; i-- {
+			if len(label) == 0 || fgen.targets[i].label == label {
+				target = fgen.targets[i].breakBlock
+				break
+			}
+		}
+	case token.CONTINUE:
+		// Innermost enclosing for or range statement, or the enclosing statement
+		// with the given label.
+		for i := len(fgen.targets) - 1; i >= 0; i-- {
+			if fgen.targets[i].continueBlock == nil {
+				continue
+			}
+			if len(label) == 0 || fgen.targets[i].label == label {
+				target = fgen.targets[i].continueBlock
+				break
+			}
+		}
+	case token.FALLTHROUGH:
+		// Fallthrough statements are handled by lowerSwitchStmt.
+		fgen.gen.Errorf("invalid fallthrough statement; expected final statement of case clause")
+		return
+	case token.GOTO:
+		// Labeled statements may be located after the goto statement; their
+		// basic blocks are created before lowering the function body.
+		target = fgen.labels[label]
+	default:
+		panic(fmt.Errorf("support for branch statement with token %v not yet implemented", goBranchStmt.Tok))
+	}
+	if target == nil {
+		fgen.gen.Errorf("invalid %v statement; unable to locate target statement", goBranchStmt.Tok)
+		return
+	}
+	fgen.cur.NewBr(target)
+	// Statements following the branch statement are unreachable, unless
+	// labeled.
+	fgen.cur = fgen.f.NewBlock("")
+}
+
+// lowerDeclStmt lowers the Go declaration statement to LLVM IR, emitting to f.
+func (fgen *funcGen) lowerDeclStmt(goDeclStmt *ast.DeclStmt) {
+	goGenDecl, ok := goDeclStmt.Decl.(*ast.GenDecl)
+	if !ok {
+		fgen.gen.Errorf("invalid declaration of declaration statement; expected *ast.GenDecl, got %T", goDeclStmt.Decl)
+		return
+	}
+	switch goGenDecl.Tok {
+	case token.CONST:
+		// Constants are folded at their use sites.
+	case token.TYPE:
+		// Type definitions are lowered on first use.
+	case token.VAR:
+		for _, goSpec := range goGenDecl.Specs {
+			fgen.lowerLocalValueSpec(goSpec.(*ast.ValueSpec))
+		}
+	default:
+		panic(fmt.Errorf("support for declaration statement with token %v not yet implemented", goGenDecl.Tok))
+	}
+}
+
+// lowerLocalValueSpec lowers the Go local variable declaration to LLVM IR,
+// emitting to f. Variables without initializers are zero-initialized, so that
+// each execution of the declaration (e.g. in a loop body) yields the zero value.
+func (fgen *funcGen) lowerLocalValueSpec(goSpec *ast.ValueSpec) {
+	var goLhs []ast.Expr
+	for _, goName := range goSpec.Names {
+		goLhs = append(goLhs, goName)
+	}
+	var vs []value.Value
+	if len(goSpec.Values) > 0 {
+		var err error
+		vs, err = fgen.lowerAssignValues(goLhs, goSpec.Values)
+		if err != nil {
+			fgen.gen.eh(err)
+			return
+		}
+	}
+	for i, goName := range goSpec.Names {
+		if isBlank(goName) {
+			continue
+		}
+		// The type is determined by the type-checker, as the type may be omitted
+		// from the specifier (e.g. `var x = f()`).
+		obj := fgen.gen.pkg.TypesInfo.Defs[goName]
+		t, err := fgen.gen.irType(obj.Type())
+		if err != nil {
+			fgen.gen.eh(err)
+			continue
+		}
+		mem := fgen.newVar(obj, t)
+		if vs != nil {
+			fgen.newStore(vs[i], mem)
+		} else {
+			fgen.newStore(zeroValue(t), mem)
+		}
+	}
+}
+
 // lowerExprStmt lowers the Go expression statement to LLVM IR, emitting to f.
 func (fgen *funcGen) lowerExprStmt(goExprStmt *ast.ExprStmt) {
 	if _, err := fgen.lowerExpr(goExprStmt.X); err != nil {
@@ -86,7 +405,7 @@ func (fgen *funcGen) lowerForStmt(goForStmt *ast.ForStmt) {
 	fgen.f.Blocks = append(fgen.f.Blocks, condBlock)
 	if goForStmt.Cond != nil {
 		// Condition.
-		cond, err := fgen.lowerExpr(goForStmt.Cond)
+		cond, err := fgen.lowerExprUse(goForStmt.Cond)
 		if err != nil {
 			fgen.gen.eh(err)
 			return
@@ -99,9 +418,14 @@ func (fgen *funcGen) lowerForStmt(goForStmt *ast.ForStmt) {
 	// Body.
 	fgen.cur = bodyBlock
 	fgen.f.Blocks = append(fgen.f.Blocks, bodyBlock)
+	pop := fgen.pushTarget(goForStmt, followBlock, postBlock)
 	fgen.lowerStmt(goForStmt.Body)
+	pop()
 	// Post.
-	fgen.cur.NewBr(postBlock)
+	if fgen.cur.Term == nil {
+		// Body not terminated (e.g. by a return statement).
+		fgen.cur.NewBr(postBlock)
+	}
 	fgen.cur = postBlock
 	fgen.f.Blocks = append(fgen.f.Blocks, postBlock)
 	if goForStmt.Post != nil {
@@ -115,6 +439,11 @@ func (fgen *funcGen) lowerForStmt(goForStmt *ast.ForStmt) {
 
 // lowerIfStmt lowers the Go if-statement to LLVM IR, emitting to f.
 func (fgen *funcGen) lowerIfStmt(goIfStmt *ast.IfStmt) {
+	// Lower conditional move idiom (e.g. max and min patterns) to select
+	// instruction.
+	if fgen.lowerCondMove(goIfStmt) {
+		return
+	}
 	// Initialization statement.
 	if goIfStmt.Init != nil {
 		fgen.lowerStmt(goIfStmt.Init)
@@ -159,6 +488,194 @@ func (fgen *funcGen) lowerIfStmt(goIfStmt *ast.IfStmt) {
 	fgen.f.Blocks = append(fgen.f.Blocks, followBlock)
 }
 
+// lowerCondMove lowers the Go if-statement to a select instruction if it has
+// the form of a conditional move, emitting to f. The boolean return value
+// indicates whether the if-statement was lowered.
+//
+// The conditional move idiom has the following form, where a and b are free
+// from side effects (e.g. `if x > y { m = x } else { m = y }`).
+//
+//	if cond {
+//		v = a
+//	} else {
+//		v = b
+//	}
+func (fgen *funcGen) lowerCondMove(goIfStmt *ast.IfStmt) bool {
+	if goIfStmt.Init != nil {
+		return false
+	}
+	goElse, ok := goIfStmt.Else.(*ast.BlockStmt)
+	if !ok {
+		return false
+	}
+	goTrueAssign, ok := singleAssign(goIfStmt.Body)
+	if !ok {
+		return false
+	}
+	goFalseAssign, ok := singleAssign(goElse)
+	if !ok {
+		return false
+	}
+	info := fgen.gen.pkg.TypesInfo
+	goDst := goTrueAssign.Lhs[0].(*ast.Ident)
+	dstObj := info.ObjectOf(goDst)
+	if dstObj == nil || dstObj != info.ObjectOf(goFalseAssign.Lhs[0].(*ast.Ident)) {
+		return false
+	}
+	goTrueValue, goFalseValue := goTrueAssign.Rhs[0], goFalseAssign.Rhs[0]
+	if !fgen.isPure(goTrueValue) || !fgen.isPure(goFalseValue) {
+		return false
+	}
+	// Condition.
+	cond, err := fgen.lowerExprUse(goIfStmt.Cond)
+	if err != nil {
+		fgen.gen.eh(err)
+		return true
+	}
+	// Convert values to the type of the destination (e.g. untyped nil or
+	// concrete values assigned to interface variables).
+	x, err := fgen.lowerExprUse(goTrueValue)
+	if err != nil {
+		fgen.gen.eh(err)
+		return true
+	}
+	x, err = fgen.convert(x, info.TypeOf(goTrueValue), dstObj.Type())
+	if err != nil {
+		fgen.gen.eh(err)
+		return true
+	}
+	y, err := fgen.lowerExprUse(goFalseValue)
+	if err != nil {
+		fgen.gen.eh(err)
+		return true
+	}
+	y, err = fgen.convert(y, info.TypeOf(goFalseValue), dstObj.Type())
+	if err != nil {
+		fgen.gen.eh(err)
+		return true
+	}
+	dst, err := fgen.lowerExprAddr(goDst)
+	if err != nil {
+		fgen.gen.eh(err)
+		return true
+	}
+	v := fgen.cur.NewSelect(cond, x, y)
+	fgen.newStore(v, dst)
+	return true
+}
+
+// lowerIncDecStmt lowers the Go increment or decrement statement to LLVM IR,
+// emitting to f.
+func (fgen *funcGen) lowerIncDecStmt(goIncDecStmt *ast.IncDecStmt) {
+	update := func(x value.Value) (value.Value, error) {
+		return fgen.lowerIncDec(goIncDecStmt, x)
+	}
+	if goIndexExpr, ok := fgen.mapIndexExpr(goIncDecStmt.X); ok {
+		// Map element (e.g. `m[k]++`).
+		if err := fgen.lowerMapUpdate(goIndexExpr, update); err != nil {
+			fgen.gen.eh(err)
+		}
+		return
+	}
+	mem, x, err := fgen.lowerExprAddrUse(goIncDecStmt.X)
+	if err != nil {
+		fgen.gen.eh(err)
+		return
+	}
+	result, err := update(x)
+	if err != nil {
+		fgen.gen.eh(err)
+		return
+	}
+	fgen.newStore(result, mem)
+}
+
+// lowerIncDec returns the value x incremented or decremented by one, as
+// specified by the Go increment or decrement statement, emitting to f.
+func (fgen *funcGen) lowerIncDec(goIncDecStmt *ast.IncDecStmt, x value.Value) (value.Value, error) {
+	t, ok := x.Type().(*types.IntType)
+	if !ok {
+		return nil, errors.Errorf("support for %v statement with operand of type %v not yet implemented; expected integer type", goIncDecStmt.Tok, fgen.gen.pkg.TypesInfo.TypeOf(goIncDecStmt.X))
+	}
+	one := constant.NewInt(t, 1)
+	switch goIncDecStmt.Tok {
+	case token.INC: // ++
+		return fgen.cur.NewAdd(x, one), nil
+	case token.DEC: // --
+		return fgen.cur.NewSub(x, one), nil
+	default:
+		panic(fmt.Errorf("support for increment or decrement statement with token %v not yet implemented", goIncDecStmt.Tok))
+	}
+}
+
+// lowerLabeledStmt lowers the Go labeled statement to LLVM IR, emitting to f.
+func (fgen *funcGen) lowerLabeledStmt(goLabeledStmt *ast.LabeledStmt) {
+	block, ok := fgen.labels[goLabeledStmt.Label.Name]
+	if !ok {
+		fgen.gen.Errorf("unable to locate basic block of label %q", goLabeledStmt.Label.Name)
+		return
+	}
+	if fgen.cur.Term == nil {
+		// Fall through to labeled statement.
+		fgen.cur.NewBr(block)
+	}
+	fgen.cur = block
+	fgen.f.Blocks = append(fgen.f.Blocks, block)
+	fgen.lowerStmt(goLabeledStmt.Stmt)
+}
+
+// indexLabels creates a basic block for each labeled statement of the Go
+// function body, so that goto statements may refer to labels defined later in
+// the function body.
+func (fgen *funcGen) indexLabels(goBody *ast.BlockStmt) {
+	ast.Inspect(goBody, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			// Function literals have their own labels.
+			return false
+		case *ast.LabeledStmt:
+			fgen.labels[n.Label.Name] = ir.NewBlock("")
+			fgen.stmtLabels[n.Stmt] = n.Label.Name
+		}
+		return true
+	})
+}
+
+// lowerRangeStmt lowers the Go range-statement to LLVM IR, emitting to f.
+func (fgen *funcGen) lowerRangeStmt(goRangeStmt *ast.RangeStmt) {
+	goType := fgen.gen.subst(fgen.gen.pkg.TypesInfo.TypeOf(goRangeStmt.X))
+	switch goUnderlying := goType.Underlying().(type) {
+	case *gotypes.Chan:
+		fgen.lowerChanRange(goRangeStmt, goUnderlying)
+	default:
+		panic(fmt.Errorf("support for range statement over %v not yet implemented", goType))
+	}
+}
+
+// lowerRangeVar returns the memory address of the given iteration variable of a
+// Go range-statement, emitting to f. New variables are declared when tok is
+// token.DEFINE (e.g. `for i, v := range xs`), and existing variables are
+// assigned to otherwise (e.g. `for i, v = range xs`). A nil memory address is
+// returned for omitted and blank iteration variables.
+func (fgen *funcGen) lowerRangeVar(goExpr ast.Expr, tok token.Token) (value.Value, error) {
+	if goExpr == nil || isBlank(goExpr) {
+		return nil, nil
+	}
+	if tok != token.DEFINE {
+		return fgen.lowerExprAddr(goExpr)
+	}
+	goIdent, ok := goExpr.(*ast.Ident)
+	if !ok {
+		return nil, errors.Errorf("invalid iteration variable of range statement; expected *ast.Ident, got %T", goExpr)
+	}
+	obj := fgen.gen.pkg.TypesInfo.Defs[goIdent]
+	t, err := fgen.gen.irType(obj.Type())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return fgen.newVar(obj, t), nil
+}
+
 // lowerReturnStmt lowers the Go return statement to LLVM IR, emitting to f.
 func (fgen *funcGen) lowerReturnStmt(goRetStmt *ast.ReturnStmt) {
 	results, err := fgen.lowerExprs(goRetStmt.Results)
@@ -166,6 +683,20 @@ func (fgen *funcGen) lowerReturnStmt(goRetStmt *ast.ReturnStmt) {
 		fgen.gen.eh(err)
 		return
 	}
+	// Convert results to the result types of the function (e.g. `return
+	// int32(x)` needs no conversion, but `return x` in a function with an
+	// interface result boxes x).
+	if goResults := fgen.sig.Results(); goResults.Len() == len(results) {
+		for i, result := range results {
+			goType := fgen.gen.pkg.TypesInfo.TypeOf(goRetStmt.Results[i])
+			result, err := fgen.convert(result, goType, goResults.At(i).Type())
+			if err != nil {
+				fgen.gen.eh(err)
+				return
+			}
+			results[i] = result
+		}
+	}
 	switch len(results) {
 	case 0:
 		// void return.
@@ -181,7 +712,11 @@ func (fgen *funcGen) lowerReturnStmt(goRetStmt *ast.ReturnStmt) {
 
 // lowerSwitchStmt lowers the Go switch-statement to LLVM IR, emitting to f.
 func (fgen *funcGen) lowerSwitchStmt(goSwitchStmt *ast.SwitchStmt) {
-	// Initialization statement.
+	// Initialization statement. Variables declared by the initialization
+	// statement (e.g. `switch x := f(); x {`) are scoped to the switch statement.
+	// As locals are resolved through their type-checker objects, they are
+	// accessible from the tag and case clauses, without being visible after the
+	// switch statement.
 	if goSwitchStmt.Init != nil {
 		fgen.lowerStmt(goSwitchStmt.Init)
 	}
@@ -204,73 +739,93 @@ func (fgen *funcGen) lowerSwitchStmt(goSwitchStmt *ast.SwitchStmt) {
 		}
 	}
 	var caseBlocks []*ir.BasicBlock
-	nextBlock := ir.NewBlock("")
+	// The default branch is taken when no other case matches, independent of
+	// its position within the switch statement.
+	var defaultBlock *ir.BasicBlock
+	//followBlock := ir.NewBlock("follow")
+	followBlock := ir.NewBlock("")
 	for _, goCase := range goCases {
-		if goCase.List != nil {
-			// case branches.
-			//caseBlock := ir.NewBlock(fmt.Sprintf("case_%d", i))
+		if goCase.List == nil {
+			// default branch.
+			//caseBlock := ir.NewBlock("default")
 			caseBlock := ir.NewBlock("")
 			caseBlocks = append(caseBlocks, caseBlock)
-			if tag != nil {
-				// Tag.
-				for _, goExpr := range goCase.List {
-					x, err := fgen.lowerExprUse(goExpr)
-					if err != nil {
-						fgen.gen.eh(err)
-						continue
-					}
-					cond, err := fgen.lowerEqual(tag, x)
-					if err != nil {
-						fgen.gen.eh(err)
-						continue
-					}
-					fgen.cur.NewCondBr(cond, caseBlock, nextBlock)
-					fgen.cur = nextBlock
-					fgen.f.Blocks = append(fgen.f.Blocks, nextBlock)
-					nextBlock = ir.NewBlock("")
+			defaultBlock = caseBlock
+			continue
+		}
+		// case branches.
+		//caseBlock := ir.NewBlock(fmt.Sprintf("case_%d", i))
+		caseBlock := ir.NewBlock("")
+		caseBlocks = append(caseBlocks, caseBlock)
+		if tag != nil {
+			// Tag.
+			for _, goExpr := range goCase.List {
+				x, err := fgen.lowerExprUse(goExpr)
+				if err != nil {
+					fgen.gen.eh(err)
+					continue
 				}
-			} else {
-				// No tag.
-				var cond value.Value
-				for _, goExpr := range goCase.List {
-					x, err := fgen.lowerExprUse(goExpr)
-					if err != nil {
-						fgen.gen.eh(err)
-						continue
-					}
-					if cond != nil {
-						cond = fgen.cur.NewOr(cond, x)
-					} else {
-						cond = x
-					}
+				cond, err := fgen.lowerEqual(tag, x)
+				if err != nil {
+					fgen.gen.eh(err)
+					continue
 				}
+				nextBlock := fgen.f.NewBlock("")
 				fgen.cur.NewCondBr(cond, caseBlock, nextBlock)
 				fgen.cur = nextBlock
-				fgen.f.Blocks = append(fgen.f.Blocks, nextBlock)
-				nextBlock = ir.NewBlock("")
 			}
 		} else {
-			// default branch.
-			//caseBlock := ir.NewBlock("default")
-			caseBlock := ir.NewBlock("")
-			caseBlocks = append(caseBlocks, caseBlock)
-			fgen.cur.NewBr(caseBlock)
+			// No tag.
+			var cond value.Value
+			for _, goExpr := range goCase.List {
+				x, err := fgen.lowerExprUse(goExpr)
+				if err != nil {
+					fgen.gen.eh(err)
+					continue
+				}
+				if cond != nil {
+					cond = fgen.cur.NewOr(cond, x)
+				} else {
+					cond = x
+				}
+			}
+			nextBlock := fgen.f.NewBlock("")
+			fgen.cur.NewCondBr(cond, caseBlock, nextBlock)
+			fgen.cur = nextBlock
 		}
 	}
+	// No case matched.
+	if defaultBlock != nil {
+		fgen.cur.NewBr(defaultBlock)
+	} else {
+		fgen.cur.NewBr(followBlock)
+	}
 	// Case bodies.
-	//followBlock := ir.NewBlock("follow")
-	followBlock := ir.NewBlock("")
+	pop := fgen.pushTarget(goSwitchStmt, followBlock, nil)
 	for i, goCase := range goCases {
 		caseBlock := caseBlocks[i]
+		fgen.f.Blocks = append(fgen.f.Blocks, caseBlock)
 		fgen.cur = caseBlock
-		for _, goStmt := range goCase.Body {
+		goBody := goCase.Body
+		// Transfer control to the body of the next case clause if the case body
+		// ends with a fallthrough statement.
+		targetBlock := followBlock
+		if isFallthrough(goBody) {
+			if i+1 >= len(goCases) {
+				fgen.gen.Errorf("invalid fallthrough statement in final case clause of switch statement")
+			} else {
+				targetBlock = caseBlocks[i+1]
+			}
+			goBody = goBody[:len(goBody)-1]
+		}
+		for _, goStmt := range goBody {
 			fgen.lowerStmt(goStmt)
 		}
 		if fgen.cur.Term == nil {
-			fgen.cur.NewBr(followBlock)
+			fgen.cur.NewBr(targetBlock)
 		}
-		fgen.f.Blocks = append(fgen.f.Blocks, caseBlock)
 	}
+	pop()
 	// Follow basic block.
 	fgen.cur = followBlock
 	fgen.f.Blocks = append(fgen.f.Blocks, followBlock)
@@ -278,18 +833,146 @@ func (fgen *funcGen) lowerSwitchStmt(goSwitchStmt *ast.SwitchStmt) {
 
 // ### [ Helper functions ] ####################################################
 
+// lowerAssignValues lowers the right-hand side values of a Go assignment or
+// variable declaration to LLVM IR, emitting to f. The values of multi-valued
+// expressions (e.g. `a, b := f()`) are unpacked, and each value is converted to
+// the type of its corresponding left-hand side operand.
+func (fgen *funcGen) lowerAssignValues(goLhs, goRhs []ast.Expr) ([]value.Value, error) {
+	info := fgen.gen.pkg.TypesInfo
+	var vs []value.Value
+	var goTypes []gotypes.Type
+	if len(goRhs) == 1 && len(goLhs) > 1 {
+		// Multi-valued expression.
+		goTuple, ok := info.TypeOf(goRhs[0]).(*gotypes.Tuple)
+		if !ok {
+			panic(fmt.Errorf("support for multi-valued expression %T not yet implemented", goRhs[0]))
+		}
+		tuple, err := fgen.lowerExprUse(goRhs[0])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		for i := 0; i < goTuple.Len(); i++ {
+			vs = append(vs, fgen.cur.NewExtractValue(tuple, uint64(i)))
+			goTypes = append(goTypes, goTuple.At(i).Type())
+		}
+	} else {
+		for _, goExpr := range goRhs {
+			v, err := fgen.lowerExprUse(goExpr)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			vs = append(vs, v)
+			goTypes = append(goTypes, info.TypeOf(goExpr))
+		}
+	}
+	if len(vs) != len(goLhs) {
+		return nil, errors.Errorf("assignment count mismatch; %d operands on the left-hand side, but %d values", len(goLhs), len(vs))
+	}
+	// Convert values to the types of the left-hand side operands.
+	for i, goExpr := range goLhs {
+		if isBlank(goExpr) {
+			// Value discarded.
+			continue
+		}
+		v, err := fgen.convert(vs[i], goTypes[i], info.TypeOf(goExpr))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		vs[i] = v
+	}
+	return vs, nil
+}
+
+// newLocal allocates stack memory for a local variable of the given type,
+// emitting to f. The memory is allocated in the entry basic block, so that it
+// is allocated once per function invocation (e.g. not once per loop
+// iteration).
+func (fgen *funcGen) newLocal(t types.Type) *ir.InstAlloca {
+	entry := fgen.f.Blocks[0]
+	mem := entry.NewAlloca(t)
+	mem.Align = alignOf(t)
+	return mem
+}
+
+// newVar allocates memory for the given Go local variable of the LLVM IR type
+// t, emitting to f. Variables captured by function literals are allocated on
+// the heap, as they may outlive the function; other variables are allocated on
+// the stack.
+func (fgen *funcGen) newVar(obj gotypes.Object, t types.Type) value.Value {
+	var mem value.Value
+	if fgen.captured[obj] {
+		mem = fgen.newObject(t)
+	} else {
+		mem = fgen.newLocal(t)
+	}
+	fgen.locals[obj] = mem
+	return mem
+}
+
+// isFallthrough reports whether the given Go case body ends with a fallthrough
+// statement.
+func isFallthrough(goBody []ast.Stmt) bool {
+	if len(goBody) == 0 {
+		return false
+	}
+	goBranchStmt, ok := goBody[len(goBody)-1].(*ast.BranchStmt)
+	return ok && goBranchStmt.Tok == token.FALLTHROUGH
+}
+
+// isBlank reports whether the given Go expression is the blank identifier.
+func isBlank(goExpr ast.Expr) bool {
+	goIdent, ok := goExpr.(*ast.Ident)
+	return ok && goIdent.Name == "_"
+}
+
+// singleAssign returns the assignment statement of the given Go block statement
+// if the block consists of a single assignment `v = x` to a named variable v.
+// The boolean return value indicates success.
+func singleAssign(goBlockStmt *ast.BlockStmt) (*ast.AssignStmt, bool) {
+	if len(goBlockStmt.List) != 1 {
+		return nil, false
+	}
+	goAssign, ok := goBlockStmt.List[0].(*ast.AssignStmt)
+	if !ok || goAssign.Tok != token.ASSIGN || len(goAssign.Lhs) != 1 || len(goAssign.Rhs) != 1 {
+		return nil, false
+	}
+	goIdent, ok := goAssign.Lhs[0].(*ast.Ident)
+	if !ok || goIdent.Name == "_" {
+		return nil, false
+	}
+	return goAssign, true
+}
+
+// isPure reports whether the evaluation of the given Go expression is free
+// from side effects, and may thus be evaluated unconditionally.
+func (fgen *funcGen) isPure(goExpr ast.Expr) bool {
+	if tv, ok := fgen.gen.pkg.TypesInfo.Types[goExpr]; ok && tv.Value != nil {
+		// Constant expression.
+		return true
+	}
+	switch goExpr := goExpr.(type) {
+	case *ast.Ident:
+		_, ok := fgen.gen.pkg.TypesInfo.ObjectOf(goExpr).(*gotypes.Var)
+		return ok
+	case *ast.ParenExpr:
+		return fgen.isPure(goExpr.X)
+	default:
+		return false
+	}
+}
+
 // lowerEqual lowers a Go equality comparison between a and b to LLVM IR,
 // emitting to f.
 func (fgen *funcGen) lowerEqual(a, b value.Value) (value.Value, error) {
 	if !types.Equal(a.Type(), b.Type()) {
-		return nil, errors.Errorf("type mismatch between `%s` and `%s`", a.Type(), a.Type())
+		return nil, errors.Errorf("type mismatch between `%s` and `%s`", a.Type(), b.Type())
 	}
 	t := a.Type()
 	switch {
 	case types.IsInt(t):
 		return fgen.cur.NewICmp(enum.IPredEQ, a, b), nil
 	case types.IsFloat(t):
-		// TODO: figure out when to use enum.FPredUEQ.
+		// Ordered comparison, as NaN compares unequal to every value.
 		return fgen.cur.NewFCmp(enum.FPredOEQ, a, b), nil
 	default:
 		panic(fmt.Errorf("support for equality comparison of type %v not yet implemented", t))
@@ -0,0 +1,225 @@
+package lower
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSwitchEnum(t *testing.T) {
+	const src = `package p
+
+type Color int
+
+const (
+	Red Color = iota
+	Green
+	Blue
+)
+
+func f(c Color) int {
+	switch c {
+	case Red:
+		return 1
+	case Green, Blue:
+		return 2
+	}
+	return 0
+}
+`
+	def := mustFuncDef(t, lowerSource(t, src), "f")
+	// One comparison per case expression, against the folded constant values.
+	wantCount(t, def, "icmp eq", 3)
+	wantIR(t, def, ", 0\n", ", 1\n", ", 2\n")
+}
+
+func TestCondMove(t *testing.T) {
+	const src = `package p
+
+func max(x, y int) int {
+	var m int
+	if x > y {
+		m = x
+	} else {
+		m = y
+	}
+	return m
+}
+
+func either(ok bool, p, q *int) interface{} {
+	var v interface{}
+	if ok {
+		v = p
+	} else {
+		v = q
+	}
+	return v
+}
+`
+	module := lowerSource(t, src)
+	def := mustFuncDef(t, module, "max")
+	wantIR(t, def, "icmp sgt i64", "select i1")
+	rejectIR(t, def, "br ")
+	// Operands are converted to the type of the destination.
+	def = mustFuncDef(t, module, "either")
+	wantIR(t, def, "select i1 %", "%toy.eface %")
+	rejectIR(t, def, "br ")
+}
+
+func TestRedeclaredVar(t *testing.T) {
+	const src = `package p
+
+func g() (int, error) {
+	return 0, nil
+}
+
+func f() error {
+	a, err := g()
+	b, err := g()
+	_, _ = a, b
+	return err
+}
+`
+	def := mustFuncDef(t, lowerSource(t, src), "f")
+	// Stack memory is allocated for a, b and err once.
+	wantCount(t, def, "alloca i64", 2)
+	wantCount(t, def, "alloca %toy.iface", 1)
+}
+
+func TestSwitchInit(t *testing.T) {
+	const src = `package p
+
+func f() int { return 2 }
+
+func g() int {
+	switch x := f(); x {
+	default:
+		return 0
+	case 1, 2:
+		return x
+	}
+}
+`
+	def := mustFuncDef(t, lowerSource(t, src), "g")
+	// The switch init variable is in scope of the tag and the case bodies.
+	wantIR(t, def, "call i64 @f()")
+	wantCount(t, def, "icmp eq", 2)
+	// The default branch is taken only after all cases have been tested, even
+	// when it precedes them in the switch statement.
+	i := strings.Index(def, "br ")
+	if i == -1 {
+		t.Fatalf("unable to locate branch in:\n%s", def)
+	}
+	if br := def[i:]; !strings.HasPrefix(br, "br i1 ") {
+		t.Errorf("expected conditional branch of first case before default branch; got %q", br)
+	}
+}
+
+func TestStructCopy(t *testing.T) {
+	const src = `package p
+
+type Point struct {
+	X, Y int
+}
+
+type Big struct {
+	A [16]int
+}
+
+func small(b Point) Point {
+	var a Point
+	a = b
+	return a
+}
+
+func big(b Big) Big {
+	var a Big
+	a = b
+	return a
+}
+`
+	module := lowerSource(t, src)
+	// Small aggregates are copied using load and store.
+	def := mustFuncDef(t, module, "small")
+	wantIR(t, def, "load %Point, %Point* ", "store %Point ")
+	rejectIR(t, def, "memcpy")
+	// Large aggregates are copied using memcpy.
+	def = mustFuncDef(t, module, "big")
+	wantIR(t, def, "call void @llvm.memcpy.p0i8.p0i8.i64(", "i64 128, i1 false)")
+}
+
+func TestGotoLoop(t *testing.T) {
+	const src = `package p
+
+func sum(n int) int {
+	s, i := 0, 0
+loop:
+	if i < n {
+		s += i
+		i++
+		goto loop
+	}
+	return s
+}
+`
+	def := mustFuncDef(t, lowerSource(t, src), "sum")
+	// The label starts a basic block, branched to on entry and by the backward
+	// goto statement.
+	wantCount(t, def, "icmp slt i64", 1)
+	wantCount(t, def, "br i1 ", 1)
+	wantCount(t, def, "br label ", 2)
+}
+
+func TestShadowedLocals(t *testing.T) {
+	const src = `package p
+
+func f() int {
+	x := 1
+	{
+		x := 2
+		x++
+	}
+	return x
+}
+`
+	def := mustFuncDef(t, lowerSource(t, src), "f")
+	// Each variable has its own stack memory.
+	wantCount(t, def, "alloca i64", 2)
+	dst := func(store string) string {
+		i := strings.Index(def, store)
+		if i == -1 {
+			t.Fatalf("unable to locate %q in:\n%s", store, def)
+		}
+		line := def[i+len(store):]
+		if end := strings.IndexAny(line, ",\n"); end != -1 {
+			line = line[:end]
+		}
+		return line
+	}
+	outer, inner := dst("store i64 1, i64* "), dst("store i64 2, i64* ")
+	if outer == inner {
+		t.Errorf("shadowed variables share stack memory %s in:\n%s", outer, def)
+	}
+}
+
+func TestInfiniteLoop(t *testing.T) {
+	const src = `package p
+
+func f() {
+	for {
+	}
+}
+
+func g() int {
+	for {
+	}
+}
+`
+	module := lowerSource(t, src)
+	// The follow basic block of the loop has no predecessors, and is thus
+	// terminated by unreachable rather than a return.
+	for _, name := range []string{"f", "g"} {
+		def := mustFuncDef(t, module, name)
+		wantIR(t, def, "unreachable")
+		rejectIR(t, def, "ret ")
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"go/ast"
 	gotypes "go/types"
+	"strings"
 
 	"github.com/llir/llvm/ir/types"
+	"github.com/pkg/errors"
 )
 
 // irTypeOf returns the LLVM IR type of the given Go expression. It is valid to
@@ -18,13 +20,269 @@ func (gen *Generator) irTypeOf(goExpr ast.Expr) (types.Type, error) {
 // irType returns the LLVM IR type corresponding to the given Go type.
 func (gen *Generator) irType(goType gotypes.Type) (types.Type, error) {
 	switch goType := goType.(type) {
+	case *gotypes.Array:
+		return gen.irArrayType(goType)
 	case *gotypes.Basic:
 		return gen.irBasicType(goType), nil
+	case *gotypes.Chan:
+		return gen.chanType(), nil
+	case *gotypes.Interface:
+		return gen.irInterfaceType(goType), nil
+	case *gotypes.Map:
+		return gen.mapType(), nil
+	case *gotypes.Named:
+		return gen.irNamedType(goType)
+	case *gotypes.Pointer:
+		return gen.irPointerType(goType)
+	case *gotypes.Signature:
+		return gen.irSignatureType(goType)
+	case *gotypes.Slice:
+		return gen.irSliceType(goType)
+	case *gotypes.TypeParam:
+		// Type parameter of generic function instance.
+		goTypeArg, ok := gen.typeArgs[goType]
+		if !ok {
+			return nil, errors.Errorf("unable to locate type argument of type parameter %v", goType)
+		}
+		return gen.irType(goTypeArg)
+	case *gotypes.Struct:
+		return gen.irStructType(goType)
 	default:
 		panic(fmt.Errorf("support for Go type %T not yet implemented", goType))
 	}
 }
 
+// irArrayType returns the LLVM IR type corresponding to the given Go array type.
+func (gen *Generator) irArrayType(goType *gotypes.Array) (types.Type, error) {
+	// The array length has already been evaluated by the type-checker, thus
+	// lengths defined by constant expressions (e.g. `[2*n]int`) and by calls to
+	// unsafe.Sizeof (e.g. `[unsafe.Sizeof(x)]byte`) are resolved alike.
+	n := goType.Len()
+	if n < 0 {
+		return nil, errors.Errorf("invalid length of array type %v; expected constant non-negative length, got %d", goType, n)
+	}
+	elemType, err := gen.irType(goType.Elem())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return types.NewArray(uint64(n), elemType), nil
+}
+
+// chanType returns the LLVM IR type of channels, which is a pointer to a
+// runtime channel object. The channel direction and element type are not part
+// of the representation, as channel operations are handled by the runtime.
+func (gen *Generator) chanType() types.Type {
+	const name = "toy.chan"
+	t, ok := gen.typeDefs[name]
+	if !ok {
+		t = &types.StructType{Opaque: true}
+		t.SetName(name)
+		gen.typeDefs[name] = t
+	}
+	return types.NewPointer(t)
+}
+
+// irInterfaceType returns the LLVM IR type corresponding to the given Go
+// interface type.
+//
+// As in the Go runtime, empty interfaces (eface) and interfaces with methods
+// (iface) use distinct two-word representations. The empty interface has no
+// methods to dispatch, so it stores a type descriptor directly, while method
+// interfaces store an itable holding the type descriptor and method table of
+// the dynamic type.
+func (gen *Generator) irInterfaceType(goType *gotypes.Interface) types.Type {
+	if goType.Empty() {
+		return gen.efaceType()
+	}
+	return gen.ifaceType()
+}
+
+// efaceType returns the LLVM IR type of empty interfaces.
+func (gen *Generator) efaceType() types.Type {
+	const name = "toy.eface"
+	if t, ok := gen.typeDefs[name]; ok {
+		return t
+	}
+	t := types.NewStruct(
+		types.NewPointer(types.I8), // type descriptor
+		types.NewPointer(types.I8), // data
+	)
+	t.SetName(name)
+	gen.typeDefs[name] = t
+	return t
+}
+
+// ifaceType returns the LLVM IR type of interfaces with methods.
+func (gen *Generator) ifaceType() types.Type {
+	const name = "toy.iface"
+	if t, ok := gen.typeDefs[name]; ok {
+		return t
+	}
+	t := types.NewStruct(
+		types.NewPointer(types.I8), // itable
+		types.NewPointer(types.I8), // data
+	)
+	t.SetName(name)
+	gen.typeDefs[name] = t
+	return t
+}
+
+// irNamedType returns the LLVM IR type definition corresponding to the given Go
+// named type, adding it to the module on first use.
+func (gen *Generator) irNamedType(goType *gotypes.Named) (types.Type, error) {
+	goTypeName := goType.Obj()
+	name := goTypeName.Name()
+	if pkg := goTypeName.Pkg(); pkg != nil && pkg != gen.pkg.Types {
+		// Qualify type names of imported packages.
+		name = fmt.Sprintf("%s.%s", pkg.Name(), name)
+	}
+	if goTypeArgs := goType.TypeArgs(); goTypeArgs.Len() > 0 {
+		// Instance of generic type (e.g. `Stack[int]`), with a distinct type
+		// definition per list of concrete type arguments. Type arguments may
+		// refer to the type parameters of the generic function instance being
+		// lowered (e.g. `Stack[[]T]` in the body of a generic function).
+		goType = gen.subst(goType).(*gotypes.Named)
+		goTypeArgs = goType.TypeArgs()
+		var argNames []string
+		for i := 0; i < goTypeArgs.Len(); i++ {
+			argNames = append(argNames, gotypes.TypeString(goTypeArgs.At(i), gen.qualifier))
+		}
+		name = fmt.Sprintf("%s[%s]", name, strings.Join(argNames, ","))
+	}
+	if t, ok := gen.typeDefs[name]; ok {
+		return t, nil
+	}
+	if goStructType, ok := goType.Underlying().(*gotypes.Struct); ok {
+		// Add the type definition before lowering the struct fields, to support
+		// recursive types (e.g. `type Node struct { next *Node }`).
+		t := types.NewStruct()
+		t.SetName(name)
+		t.Packed = gen.packed[goType.Obj()]
+		gen.typeDefs[name] = t
+		fields, err := gen.irStructFields(goStructType)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		t.Fields = fields
+		return t, nil
+	}
+	underlying, err := gen.irType(goType.Underlying())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	t := newTypeDef(name, underlying)
+	gen.typeDefs[name] = t
+	return t, nil
+}
+
+// irPointerType returns the LLVM IR type corresponding to the given Go pointer
+// type.
+func (gen *Generator) irPointerType(goType *gotypes.Pointer) (types.Type, error) {
+	elemType, err := gen.irType(goType.Elem())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return types.NewPointer(elemType), nil
+}
+
+// irSignatureType returns the LLVM IR type corresponding to the given Go
+// function signature, which is a pointer to a closure object. Nil function
+// values are represented by null pointers.
+//
+// The first field of closure objects holds a pointer to the function, which is
+// invoked with a pointer to the closure object as hidden first argument
+// (through which captured variables are accessed); the pointers to captured
+// variables follow the first field.
+func (gen *Generator) irSignatureType(goType *gotypes.Signature) (types.Type, error) {
+	funcType, err := gen.irFuncType(goType)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return types.NewPointer(types.NewStruct(types.NewPointer(closureFuncType(funcType)))), nil
+}
+
+// closureFuncType returns the LLVM IR function type of closure functions with
+// the given function type, with a pointer to the closure object prepended as
+// hidden first parameter.
+func closureFuncType(funcType *types.FuncType) *types.FuncType {
+	params := append([]types.Type{types.NewPointer(types.I8)}, funcType.Params...)
+	return types.NewFunc(funcType.RetType, params...)
+}
+
+// irFuncType returns the LLVM IR function type of functions with the given Go
+// function signature (excluding the receiver).
+func (gen *Generator) irFuncType(goType *gotypes.Signature) (*types.FuncType, error) {
+	var params []types.Type
+	goParams := goType.Params()
+	for i := 0; i < goParams.Len(); i++ {
+		// The type of variadic parameters is already of slice type.
+		param, err := gen.irType(goParams.At(i).Type())
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		params = append(params, param)
+	}
+	var results []types.Type
+	goResults := goType.Results()
+	for i := 0; i < goResults.Len(); i++ {
+		result, err := gen.irType(goResults.At(i).Type())
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		results = append(results, result)
+	}
+	var retType types.Type
+	switch len(results) {
+	case 0:
+		// void return.
+		retType = types.Void
+	case 1:
+		// single value return.
+		retType = results[0]
+	default:
+		// multiple value return.
+		retType = types.NewStruct(results...)
+	}
+	return types.NewFunc(retType, params...), nil
+}
+
+// irSliceType returns the LLVM IR type corresponding to the given Go slice type.
+func (gen *Generator) irSliceType(goType *gotypes.Slice) (types.Type, error) {
+	elemType, err := gen.irType(goType.Elem())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	t := types.NewStruct(
+		types.NewPointer(elemType), // data
+		types.I64,                  // len
+		types.I64,                  // cap
+	)
+	return t, nil
+}
+
+// irStructType returns the LLVM IR type corresponding to the given Go struct
+// type.
+func (gen *Generator) irStructType(goType *gotypes.Struct) (types.Type, error) {
+	fields, err := gen.irStructFields(goType)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return types.NewStruct(fields...), nil
+}
+
+// irStructFields returns the LLVM IR field types of the given Go struct type.
+func (gen *Generator) irStructFields(goType *gotypes.Struct) ([]types.Type, error) {
+	var fields []types.Type
+	for i := 0; i < goType.NumFields(); i++ {
+		field, err := gen.irType(goType.Field(i).Type())
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
 // CPU word size in number of bits.
 const cpuWordSize = 64
 
@@ -113,3 +371,51 @@ func (gen *Generator) irBasicType(goType *gotypes.Basic) types.Type {
 		panic(fmt.Errorf("support for basic type of kind %v not yet implemented", goType.Kind()))
 	}
 }
+
+// ### [ Helper functions ] ####################################################
+
+// qualifier returns the name used to qualify type names of the given Go
+// package, omitting the qualifier of the package being compiled.
+func (gen *Generator) qualifier(pkg *gotypes.Package) string {
+	if pkg == gen.pkg.Types {
+		return ""
+	}
+	return pkg.Name()
+}
+
+// newTypeDef returns a copy of the given LLVM IR type with the specified type
+// name. The type is copied, as the underlying type may be shared (e.g.
+// types.I64) and should not be renamed.
+func newTypeDef(name string, t types.Type) types.Type {
+	var def types.Type
+	switch t := t.(type) {
+	case *types.VoidType:
+		tt := *t
+		def = &tt
+	case *types.FuncType:
+		tt := *t
+		def = &tt
+	case *types.IntType:
+		tt := *t
+		def = &tt
+	case *types.FloatType:
+		tt := *t
+		def = &tt
+	case *types.PointerType:
+		tt := *t
+		def = &tt
+	case *types.VectorType:
+		tt := *t
+		def = &tt
+	case *types.ArrayType:
+		tt := *t
+		def = &tt
+	case *types.StructType:
+		tt := *t
+		def = &tt
+	default:
+		panic(fmt.Errorf("support for type definition of LLVM IR type %T not yet implemented", t))
+	}
+	def.SetName(name)
+	return def
+}
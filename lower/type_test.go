@@ -0,0 +1,124 @@
+package lower
+
+import "testing"
+
+func TestArraySizeConstExpr(t *testing.T) {
+	const src = `package p
+
+import "unsafe"
+
+const n = 2
+
+var x int64
+
+var a [n*3 + 1]int32
+
+var b [unsafe.Sizeof(x)]byte
+`
+	module := lowerSource(t, src)
+	wantIR(t, module, "[7 x i32]", "[8 x i8]")
+}
+
+func TestEmptyIfaceLayout(t *testing.T) {
+	const src = `package p
+
+func f(x interface{}) interface{} {
+	return x
+}
+
+type Stringer interface {
+	String() string
+}
+
+func g(x Stringer) Stringer {
+	return x
+}
+`
+	module := lowerSource(t, src)
+	// Type descriptor and data.
+	wantIR(t, module, "%toy.eface = type { i8*, i8* }")
+	wantIR(t, mustFuncDef(t, module, "f"), "define %toy.eface @f(%toy.eface")
+	// Itable and data.
+	wantIR(t, module, "%toy.iface = type { i8*, i8* }")
+	wantIR(t, mustFuncDef(t, module, "g"), "define %toy.iface @g(%toy.iface")
+}
+
+func TestPackageConstArraySize(t *testing.T) {
+	const src = `package p
+
+var a [N]int
+
+var x = 1 << N
+
+const N = 8
+`
+	module := lowerSource(t, src)
+	wantIR(t, module, "[8 x i64]", "@x = global i64 256")
+}
+
+func TestPackedStruct(t *testing.T) {
+	const src = `package p
+
+//toy:packed
+type Header struct {
+	Tag  uint8
+	Size uint32
+}
+
+type Padded struct {
+	Tag  uint8
+	Size uint32
+}
+
+func f(h *Header) uint32 {
+	return h.Size
+}
+`
+	module := lowerSource(t, src)
+	wantIR(t, module, "%Header = type <{ i8, i32 }>", "%Padded = type { i8, i32 }")
+	// Fields of packed structs are not padded, and thus accessed with an
+	// alignment of one byte.
+	wantIR(t, mustFuncDef(t, module, "f"), "load i32, i32* ", ", align 1")
+}
+
+func TestPointerType(t *testing.T) {
+	const src = `package p
+
+func f(p *int32, pp **int8) *int32 {
+	return p
+}
+`
+	def := mustFuncDef(t, lowerSource(t, src), "f")
+	wantIR(t, def, "define i32* @f(i32* %p, i8** %pp)")
+}
+
+func TestSliceType(t *testing.T) {
+	const src = `package p
+
+func f(s []int) []int {
+	return s
+}
+
+func g() []int {
+	return make([]int, 1)
+}
+`
+	module := lowerSource(t, src)
+	// Slices are represented by the data pointer, length and capacity.
+	wantIR(t, mustFuncDef(t, module, "f"), "define { i64*, i64, i64 } @f({ i64*, i64, i64 } %s)")
+	wantIR(t, mustFuncDef(t, module, "g"), "ret { i64*, i64, i64 } ")
+}
+
+func TestArrayType(t *testing.T) {
+	const src = `package p
+
+func f(a [4]byte) byte {
+	return a[3]
+}
+`
+	def := mustFuncDef(t, lowerSource(t, src), "f")
+	// The array length and element type are retained.
+	wantIR(t, def, "define i8 @f([4 x i8] %a)", "alloca [4 x i8]", "getelementptr [4 x i8], [4 x i8]* ")
+	// Constant indices within the array length need no bounds check.
+	rejectIR(t, def, "@toy.panicindex")
+}
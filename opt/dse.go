@@ -0,0 +1,135 @@
+// Package opt implements optimization passes on LLVM IR functions.
+package opt
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/value"
+)
+
+// DeadStoreElim removes dead stores to stack memory of the given function; i.e.
+// stores to local variables (allocas) which are overwritten by a subsequent
+// store without an intervening load, or which are not loaded before the
+// function returns.
+//
+// Only local variables whose address does not escape are considered, as loads
+// and stores through other pointers may otherwise access their memory.
+// Volatile and atomic stores are retained. The analysis is local to each basic
+// block, except for local variables which are never loaded, all stores to which
+// are dead.
+func DeadStoreElim(f *ir.Function) {
+	locals, ok := localVars(f)
+	if !ok {
+		return
+	}
+	// Local variables loaded anywhere within the function.
+	loaded := make(map[*ir.InstAlloca]bool)
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			if load, ok := inst.(*ir.InstLoad); ok {
+				if a, ok := load.Src.(*ir.InstAlloca); ok {
+					loaded[a] = true
+				}
+			}
+		}
+	}
+	dead := make(map[*ir.InstStore]bool)
+	for _, block := range f.Blocks {
+		// pending maps from local variable to the last store to the variable
+		// within the basic block, not yet followed by a load.
+		pending := make(map[*ir.InstAlloca]*ir.InstStore)
+		for _, inst := range block.Insts {
+			switch inst := inst.(type) {
+			case *ir.InstLoad:
+				if a, ok := inst.Src.(*ir.InstAlloca); ok {
+					delete(pending, a)
+				}
+			case *ir.InstStore:
+				a, ok := inst.Dst.(*ir.InstAlloca)
+				if !ok || !locals[a] || inst.Volatile || inst.Atomic {
+					continue
+				}
+				if !loaded[a] {
+					// Variable never loaded.
+					dead[inst] = true
+					continue
+				}
+				if prev, ok := pending[a]; ok {
+					// Overwritten before load.
+					dead[prev] = true
+				}
+				pending[a] = inst
+			}
+		}
+		switch block.Term.(type) {
+		case *ir.TermRet, *ir.TermUnreachable:
+			// Stack memory is released at function exit; thus pending stores
+			// are never loaded.
+			for _, store := range pending {
+				dead[store] = true
+			}
+		}
+	}
+	if len(dead) == 0 {
+		return
+	}
+	for _, block := range f.Blocks {
+		insts := block.Insts[:0]
+		for _, inst := range block.Insts {
+			if store, ok := inst.(*ir.InstStore); ok && dead[store] {
+				continue
+			}
+			insts = append(insts, inst)
+		}
+		block.Insts = insts
+	}
+}
+
+// localVars returns the set of local variables (allocas) of the given function
+// whose address does not escape; i.e. which are only used as the source of
+// loads and the destination of stores. The boolean return value indicates
+// whether the operands of all instructions could be determined.
+func localVars(f *ir.Function) (map[*ir.InstAlloca]bool, bool) {
+	locals := make(map[*ir.InstAlloca]bool)
+	escaped := make(map[*ir.InstAlloca]bool)
+	// escape marks the given operands as escaped if local variables.
+	escape := func(ops ...value.Value) {
+		for _, op := range ops {
+			if a, ok := op.(*ir.InstAlloca); ok {
+				escaped[a] = true
+			}
+		}
+	}
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			switch inst := inst.(type) {
+			case *ir.InstAlloca:
+				locals[inst] = true
+			case *ir.InstLoad:
+				// Loads from local variables do not let the address escape.
+			case *ir.InstStore:
+				// Stores of the address of local variables let the address
+				// escape.
+				escape(inst.Src)
+			default:
+				user, ok := inst.(value.User)
+				if !ok {
+					return nil, false
+				}
+				for _, op := range user.Operands() {
+					escape(*op)
+				}
+			}
+		}
+		user, ok := block.Term.(value.User)
+		if !ok {
+			return nil, false
+		}
+		for _, op := range user.Operands() {
+			escape(*op)
+		}
+	}
+	for a := range escaped {
+		delete(locals, a)
+	}
+	return locals, true
+}
@@ -0,0 +1,67 @@
+package opt
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestDeadStoreElim(t *testing.T) {
+	m := ir.NewModule()
+	f := m.NewFunc("f", types.I64)
+	entry := f.NewBlock("")
+	a := entry.NewAlloca(types.I64)
+	// Overwritten before load.
+	s1 := entry.NewStore(constant.NewInt(types.I64, 1), a)
+	s2 := entry.NewStore(constant.NewInt(types.I64, 2), a)
+	v := entry.NewLoad(a)
+	// Not loaded before return.
+	s3 := entry.NewStore(constant.NewInt(types.I64, 3), a)
+	// Never loaded.
+	b := entry.NewAlloca(types.I64)
+	s4 := entry.NewStore(constant.NewInt(types.I64, 4), b)
+	entry.NewRet(v)
+	DeadStoreElim(f)
+	for _, dead := range []*ir.InstStore{s1, s3, s4} {
+		if hasInst(entry, dead) {
+			t.Errorf("dead store `%v` not removed", dead.LLString())
+		}
+	}
+	if !hasInst(entry, s2) {
+		t.Errorf("live store `%v` removed", s2.LLString())
+	}
+}
+
+func TestDeadStoreElimEscaped(t *testing.T) {
+	m := ir.NewModule()
+	g := m.NewFunc("g", types.Void, ir.NewParam("p", types.NewPointer(types.I64)))
+	f := m.NewFunc("f", types.I64)
+	entry := f.NewBlock("")
+	a := entry.NewAlloca(types.I64)
+	s1 := entry.NewStore(constant.NewInt(types.I64, 1), a)
+	// The address escapes; g may load the stored value.
+	entry.NewCall(g, a)
+	s2 := entry.NewStore(constant.NewInt(types.I64, 2), a)
+	s3 := entry.NewStore(constant.NewInt(types.I64, 3), a)
+	v := entry.NewLoad(a)
+	entry.NewRet(v)
+	DeadStoreElim(f)
+	// Stores to escaped local variables are retained.
+	for _, store := range []*ir.InstStore{s1, s2, s3} {
+		if !hasInst(entry, store) {
+			t.Errorf("store `%v` to escaped local variable removed", store.LLString())
+		}
+	}
+}
+
+// hasInst reports whether the given basic block contains the instruction.
+func hasInst(block *ir.BasicBlock, inst ir.Instruction) bool {
+	for _, i := range block.Insts {
+		if i == inst {
+			return true
+		}
+	}
+	return false
+}
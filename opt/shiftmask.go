@@ -0,0 +1,142 @@
+package opt
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// FoldShiftMask folds bit-field extractions of the given function; i.e. masks of
+// shifted values (e.g. `(x >> 4) & 0xF`).
+//
+// Extractions from constant values are folded to a constant, and masks which
+// retain all bits of a logical right shift are removed (e.g. `(x >> 60) & 0xF`
+// for 64-bit x). The shift instructions are retained, as they may have other
+// uses.
+func FoldShiftMask(f *ir.Function) {
+	repl := make(map[value.Value]value.Value)
+	for _, block := range f.Blocks {
+		insts := block.Insts[:0]
+		for _, inst := range block.Insts {
+			if and, ok := inst.(*ir.InstAnd); ok {
+				if v, ok := foldShiftMask(and); ok {
+					repl[and] = v
+					continue
+				}
+			}
+			insts = append(insts, inst)
+		}
+		block.Insts = insts
+	}
+	if len(repl) == 0 {
+		return
+	}
+	replaceUses(f, repl)
+}
+
+// foldShiftMask returns the value of the given mask instruction if it masks a
+// shifted value (e.g. `(x >> 4) & 0xF`) and can be folded. The boolean return
+// value indicates success.
+func foldShiftMask(and *ir.InstAnd) (value.Value, bool) {
+	shift, mask := and.X, and.Y
+	if _, ok := shift.(*constant.Int); ok {
+		// Mask on the left (e.g. `0xF & (x >> 4)`).
+		shift, mask = mask, shift
+	}
+	m, ok := mask.(*constant.Int)
+	if !ok {
+		return nil, false
+	}
+	t := m.Typ
+	if t.BitSize > 64 {
+		return nil, false
+	}
+	var x, n value.Value
+	switch shift := shift.(type) {
+	case *ir.InstShl:
+		x, n = shift.X, shift.Y
+	case *ir.InstLShr:
+		x, n = shift.X, shift.Y
+	case *ir.InstAShr:
+		x, n = shift.X, shift.Y
+	default:
+		return nil, false
+	}
+	c, ok := n.(*constant.Int)
+	if !ok || !c.X.IsUint64() || c.X.Uint64() >= t.BitSize {
+		// Shifts by at least the bit size yield poison values in LLVM IR.
+		return nil, false
+	}
+	amount := c.X.Uint64()
+	if v, ok := x.(*constant.Int); ok {
+		// Extraction from constant value.
+		bits := truncBits(v, t.BitSize)
+		switch shift.(type) {
+		case *ir.InstShl:
+			bits <<= amount
+		case *ir.InstLShr:
+			bits >>= amount
+		case *ir.InstAShr:
+			// Sign-extend to 64 bits before the arithmetic shift.
+			signed := int64(bits<<(64-t.BitSize)) >> (64 - t.BitSize)
+			bits = uint64(signed >> amount)
+		}
+		return newIntBits(t, bits&truncBits(m, t.BitSize)), true
+	}
+	if _, ok := shift.(*ir.InstLShr); ok {
+		// The mask is redundant if it retains all bits which may be set after
+		// the logical right shift.
+		ones := truncBits(constant.NewInt(t, -1), t.BitSize) >> amount
+		if truncBits(m, t.BitSize)&ones == ones {
+			return shift, true
+		}
+	}
+	return nil, false
+}
+
+// truncBits returns the bit pattern of the given integer constant truncated to
+// the given bit size (at most 64 bits).
+func truncBits(c *constant.Int, bitSize uint64) uint64 {
+	// The two's complement bit pattern of negative values is given by the low
+	// order 64 bits of the value as an int64.
+	var bits uint64
+	if c.X.IsInt64() {
+		bits = uint64(c.X.Int64())
+	} else {
+		bits = c.X.Uint64()
+	}
+	if bitSize < 64 {
+		bits &= 1<<bitSize - 1
+	}
+	return bits
+}
+
+// newIntBits returns a new integer constant of the given type with the given bit
+// pattern, sign-extended from the bit size of the type.
+func newIntBits(t *types.IntType, bits uint64) *constant.Int {
+	signed := int64(bits<<(64-t.BitSize)) >> (64 - t.BitSize)
+	return constant.NewInt(t, signed)
+}
+
+// replaceUses replaces the operands of the instructions and terminators of the
+// given function according to the given replacement map.
+func replaceUses(f *ir.Function, repl map[value.Value]value.Value) {
+	replace := func(user value.User) {
+		for _, op := range user.Operands() {
+			if v, ok := repl[*op]; ok {
+				*op = v
+			}
+		}
+	}
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			if user, ok := inst.(value.User); ok {
+				replace(user)
+			}
+		}
+		if user, ok := block.Term.(value.User); ok {
+			replace(user)
+		}
+	}
+}
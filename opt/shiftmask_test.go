@@ -0,0 +1,108 @@
+package opt
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+func TestFoldShiftMaskConst(t *testing.T) {
+	golden := []struct {
+		// Shift instruction of x by 4, masked by mask.
+		shift func(block *ir.BasicBlock, x, n value.Value) value.Value
+		x     *constant.Int
+		mask  *constant.Int
+		want  int64
+	}{
+		// (0xABCD >> 4) & 0xF
+		{
+			shift: func(block *ir.BasicBlock, x, n value.Value) value.Value { return block.NewLShr(x, n) },
+			x:     constant.NewInt(types.I32, 0xABCD),
+			mask:  constant.NewInt(types.I32, 0xF),
+			want:  0xC,
+		},
+		// (0x0F << 4) & 0xFF, as i8; i.e. -16
+		{
+			shift: func(block *ir.BasicBlock, x, n value.Value) value.Value { return block.NewShl(x, n) },
+			x:     constant.NewInt(types.I8, 0x0F),
+			mask:  constant.NewInt(types.I8, -1),
+			want:  -16,
+		},
+		// (int8(-128) >> 4) & 0x7F; sign-extended to 0xF8 before masking.
+		{
+			shift: func(block *ir.BasicBlock, x, n value.Value) value.Value { return block.NewAShr(x, n) },
+			x:     constant.NewInt(types.I8, -128),
+			mask:  constant.NewInt(types.I8, 0x7F),
+			want:  0x78,
+		},
+		// (uint8(0x80) >> 4) & 0x7F; zero-extended to 0x08 before masking.
+		{
+			shift: func(block *ir.BasicBlock, x, n value.Value) value.Value { return block.NewLShr(x, n) },
+			x:     constant.NewInt(types.I8, -128),
+			mask:  constant.NewInt(types.I8, 0x7F),
+			want:  0x08,
+		},
+	}
+	for i, g := range golden {
+		m := ir.NewModule()
+		f := m.NewFunc("f", g.x.Typ)
+		entry := f.NewBlock("")
+		shift := g.shift(entry, g.x, constant.NewInt(g.x.Typ, 4))
+		entry.NewRet(entry.NewAnd(shift, g.mask))
+		FoldShiftMask(f)
+		got, ok := entry.Term.(*ir.TermRet).X.(*constant.Int)
+		if !ok {
+			t.Errorf("%d: expected constant return value, got %v", i, entry.Term.(*ir.TermRet).X)
+			continue
+		}
+		if !got.X.IsInt64() || got.X.Int64() != g.want {
+			t.Errorf("%d: folded value mismatch; expected %d, got %v", i, g.want, got.X)
+		}
+		for _, inst := range entry.Insts {
+			if _, ok := inst.(*ir.InstAnd); ok {
+				t.Errorf("%d: mask instruction not removed", i)
+			}
+		}
+	}
+}
+
+func TestFoldShiftMaskRedundant(t *testing.T) {
+	golden := []struct {
+		amount    int64
+		mask      int64
+		redundant bool
+	}{
+		// (x >> 60) & 0xF
+		{amount: 60, mask: 0xF, redundant: true},
+		// 0xFF retains more bits than may be set.
+		{amount: 60, mask: 0xFF, redundant: true},
+		// (x >> 56) & 0xF clears bits which may be set.
+		{amount: 56, mask: 0xF, redundant: false},
+	}
+	for i, g := range golden {
+		m := ir.NewModule()
+		x := ir.NewParam("x", types.I64)
+		f := m.NewFunc("f", types.I64, x)
+		entry := f.NewBlock("")
+		shift := entry.NewLShr(x, constant.NewInt(types.I64, g.amount))
+		and := entry.NewAnd(shift, constant.NewInt(types.I64, g.mask))
+		entry.NewRet(and)
+		FoldShiftMask(f)
+		ret := entry.Term.(*ir.TermRet).X
+		if g.redundant {
+			if ret != shift {
+				t.Errorf("%d: expected redundant mask to be removed; got return value %v", i, ret)
+			}
+			if hasInst(entry, and) {
+				t.Errorf("%d: redundant mask instruction not removed", i)
+			}
+			continue
+		}
+		if ret != and || !hasInst(entry, and) {
+			t.Errorf("%d: expected mask to be retained; got return value %v", i, ret)
+		}
+	}
+}